@@ -1,16 +1,38 @@
 package auth
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/berkkaradalan/CoreGo/database"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type Manager struct {
 	config 	*Config
 	db 		*database.MongoDB
+
+	resetLimiter	*rateLimiter
+	lockout			*rateLimiter // failed logins per email
+	loginLimiter	*rateLimiter // failed logins per IP
+
+	trustedProxies	[]netip.Prefix
+
+	cacheMu		sync.Mutex
+	cacheGen	uint64 // bumped by every user invalidation
+
+	blacklistMu			sync.Mutex
+	blacklistCheckedAt	time.Time // last query for any revoked token
+	blacklistHasEntries	bool
+	blacklistGen		uint64 // bumped by every RevokeToken
 }
 
 func New(config *Config, db *database.MongoDB) (*Manager, error) {
@@ -18,34 +40,189 @@ func New(config *Config, db *database.MongoDB) (*Manager, error) {
 		return nil, errors.New("auth secret is required")
 	}
 
+	if _, ok := config.PreviousKeys[config.KeyID]; ok && config.KeyID != "" {
+		return nil, errors.New("auth KeyID must not appear in PreviousKeys")
+	}
+
+	for _, field := range config.SignupCustomFields {
+		if reservedSignupFields[strings.ToLower(field)] {
+			return nil, fmt.Errorf("auth SignupCustomFields must not include the account field %q", field)
+		}
+	}
+
+	config.AllowedEmailDomains = normalizeDomains(config.AllowedEmailDomains)
+	config.BlockedEmailDomains = normalizeDomains(config.BlockedEmailDomains)
+
 	if config.TokenExpiry == 0 {
 		config.TokenExpiry = 60
 	}
 
+	if config.RememberMeExpiry == 0 {
+		config.RememberMeExpiry = 30 * 24 * 60
+	}
+
 	if config.DatabaseName == "" {
 		config.DatabaseName = "users"
 	}
 
-	return &Manager{
+	if config.MaxBodyBytes == 0 {
+		config.MaxBodyBytes = 1 << 20
+	}
+
+	if config.Mailer == nil {
+		config.Mailer = NoopMailer{}
+	}
+
+	if config.ResetTokenExpiry == 0 {
+		config.ResetTokenExpiry = time.Hour
+	}
+
+	if config.VerificationTokenExpiry == 0 {
+		config.VerificationTokenExpiry = 24 * time.Hour
+	}
+
+	if config.AutoLoginAfterSignup == nil {
+		config.AutoLoginAfterSignup = Bool(true)
+	}
+
+	if config.OnError == nil {
+		config.OnError = func(err error) { log.Printf("auth: %v", err) }
+	}
+
+	if config.Metrics == nil {
+		config.Metrics = NoopMetrics{}
+	}
+
+	switch config.ResponseShape {
+	case "":
+		config.ResponseShape = ShapeUserAndToken
+	case ShapeUserAndToken, ShapeTokenOnly, ShapeUserOnly:
+	default:
+		return nil, fmt.Errorf("unknown auth response shape %q", config.ResponseShape)
+	}
+
+	switch config.EmailIndex {
+	case "", EmailIndexUnique, EmailIndexCaseInsensitive:
+	default:
+		return nil, fmt.Errorf("unknown auth email index %q", config.EmailIndex)
+	}
+
+	switch config.TokenDelivery {
+	case "":
+		config.TokenDelivery = DeliverBody
+	case DeliverBody, DeliverCookie, DeliverBoth:
+	default:
+		return nil, fmt.Errorf("unknown auth token delivery %q", config.TokenDelivery)
+	}
+	config.Cookie.setDefaults()
+
+	// A user-only body carries no token, so it must be delivered as a cookie
+	if config.ResponseShape == ShapeUserOnly && config.TokenDelivery == DeliverBody {
+		return nil, errors.New("auth ShapeUserOnly requires TokenDelivery DeliverCookie or DeliverBoth")
+	}
+
+	if config.ResetRateLimit == 0 {
+		config.ResetRateLimit = 5
+	}
+
+	if config.ResetRateWindow == 0 {
+		config.ResetRateWindow = time.Hour
+	}
+
+	if config.LockoutDuration == 0 {
+		config.LockoutDuration = 15 * time.Minute
+	}
+
+	if config.LoginRateWindow == 0 {
+		config.LoginRateWindow = 15 * time.Minute
+	}
+
+	if config.EnsureIndexes == nil {
+		config.EnsureIndexes = Bool(true)
+	}
+
+	if config.AdminRole == "" {
+		config.AdminRole = "admin"
+	}
+
+	if config.ClientIPHeader == "" {
+		config.ClientIPHeader = HeaderForwardedFor
+	}
+	switch http.CanonicalHeaderKey(config.ClientIPHeader) {
+	case http.CanonicalHeaderKey(HeaderForwardedFor), http.CanonicalHeaderKey(HeaderRealIP):
+	default:
+		return nil, fmt.Errorf("unsupported auth client IP header %q", config.ClientIPHeader)
+	}
+
+	trustedProxies, err := parseTrustedProxies(config.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
+	config.Collections.setDefaults()
+
+	if config.AuditLogger == nil {
+		if db != nil {
+			config.AuditLogger = &MongoAuditLogger{DB: db, Collection: config.Collections.AuditLog}
+		} else {
+			config.AuditLogger = NoopAuditLogger{}
+		}
+	}
+
+	manager := &Manager{
 		config: config,
 		db:		db,
-	}, nil
+		trustedProxies: trustedProxies,
+	}
+	if config.ResetRateLimit > 0 {
+		manager.resetLimiter = newRateLimiter(config.ResetRateLimit, config.ResetRateWindow)
+	}
+	if config.MaxFailedLogins > 0 {
+		manager.lockout = newRateLimiter(config.MaxFailedLogins, config.LockoutDuration)
+	}
+	if config.LoginRateLimit > 0 {
+		manager.loginLimiter = newRateLimiter(config.LoginRateLimit, config.LoginRateWindow)
+	}
+
+	// Without a database there is nothing to index, e.g. in handler tests
+	if *config.EnsureIndexes && db != nil {
+		if err := manager.EnsureIndexes(); err != nil {
+			return nil, err
+		}
+	}
+
+	return manager, nil
 }
 
 // Signup creates a new user account
 func (m *Manager) Signup(req SignupRequest) (*User, string, error) {
+	return m.SignupContext(context.Background(), req)
+}
+
+// SignupContext is like Signup but runs under ctx
+func (m *Manager) SignupContext(ctx context.Context, req SignupRequest) (*User, string, error) {
 	// 1. Validate email and password
 	if req.Email == "" {
 		return nil, "", errors.New("email is required")
 	}
-	if req.Password == "" {
-		return nil, "", errors.New("password is required")
+	if err := validatePassword(req.Password); err != nil {
+		return nil, "", err
+	}
+	req.Custom = m.withDefaultCustom(req.Custom)
+	if err := m.validateCustom(req.Custom); err != nil {
+		return nil, "", err
+	}
+	if !m.emailDomainAllowed(req.Email) {
+		return nil, "", ErrEmailDomainNotAllowed
 	}
 
 	// 2. Check if user already exists
-	existingUser, _ := m.GetUserByEmail(req.Email)
+	existingUser, err := m.GetUserByEmailContext(ctx, req.Email)
+	if err != nil && !errors.Is(err, ErrUserNotFound) {
+		return nil, "", fmt.Errorf("%w: %w", ErrLookupFailed, err)
+	}
 	if existingUser != nil {
-		return nil, "", errors.New("user with this email already exists")
+		return nil, "", ErrEmailExists
 	}
 
 	// 3. Hash password
@@ -62,16 +239,38 @@ func (m *Manager) Signup(req SignupRequest) (*User, string, error) {
 		CreatedAt: time.Now(),
 	}
 
-	// 5. Save to database
-	userID, err := m.db.InsertOne(m.config.DatabaseName, user)
+	// 5. Claim the invite, then save to database
+	var claimed map[string]any
+	if m.config.RequireInvite {
+		claimed, err = m.claimInvite(ctx, req.InviteToken, req.Email)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	userID, err := m.insertUser(ctx, user)
+	if err != nil && claimed != nil {
+		if restoreErr := m.restoreInvite(ctx, claimed); restoreErr != nil {
+			m.config.OnError(fmt.Errorf("failed to restore invite for %s: %w", req.Email, restoreErr))
+		}
+	}
+	if isDuplicateEmail(err) || errors.Is(err, ErrEmailExists) {
+		// Signed up concurrently, or differs from the existing email only in case
+		return nil, "", ErrEmailExists
+	}
 	if err != nil {
 		return nil, "", errors.New("failed to create user")
 	}
 
 	user.ID = userID
+	m.config.Metrics.IncSignup()
+
+	if !*m.config.AutoLoginAfterSignup {
+		return user, "", nil
+	}
 
 	// 6. Generate token
-	token, err := m.GenerateToken(userID)
+	token, err := m.generateToken(userID, user.TokenVersion)
 	if err != nil {
 		return nil, "", errors.New("failed to generate token")
 	}
@@ -81,40 +280,76 @@ func (m *Manager) Signup(req SignupRequest) (*User, string, error) {
 
 // Login authenticates a user
 func (m *Manager) Login(req LoginRequest) (*User, string, error) {
+	return m.LoginContext(context.Background(), req)
+}
+
+// LoginContext is like Login but runs under ctx
+func (m *Manager) LoginContext(ctx context.Context, req LoginRequest) (*User, string, error) {
 	// 1. Validate input
 	if req.Email == "" || req.Password == "" {
 		return nil, "", errors.New("email and password are required")
 	}
 
+	// Lockout is keyed by email whether or not the account exists,
+	// so it doesn't reveal which emails are registered
+	lockoutKey := strings.ToLower(strings.TrimSpace(req.Email))
+	if m.lockout != nil && m.lockout.blocked(lockoutKey) {
+		return nil, "", ErrAccountLocked
+	}
+
 	// 2. Find user by email
-	user, err := m.GetUserByEmail(req.Email)
-	if err != nil {
-		return nil, "", errors.New("invalid credentials")
+	user, err := m.GetUserByEmailContext(ctx, req.Email)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		// The request was cancelled, not a failed login attempt
+		return nil, "", ctxErr
 	}
 
-	// 3. Verify password
-	if !VerifyPassword(user.Password, req.Password) {
-		return nil, "", errors.New("invalid credentials")
+	// 3. Verify password; soft-deleted accounts are not found
+	if err != nil || !VerifyPassword(user.Password, req.Password) {
+		m.config.Metrics.IncLoginFailure()
+		if m.lockout != nil {
+			m.lockout.allow(lockoutKey)
+		}
+		return nil, "", ErrInvalidCredentials
+	}
+	if m.lockout != nil {
+		m.lockout.reset(lockoutKey)
 	}
 
-	// 4. Generate token
-	token, err := m.GenerateToken(user.ID)
+	// 4. Generate token, long-lived when the user asked to be remembered
+	expiry := m.config.TokenExpiry
+	if req.RememberMe {
+		expiry = m.config.RememberMeExpiry
+	}
+	token, err := m.signToken(user.ID, user.TokenVersion, time.Duration(expiry)*time.Minute, time.Time{})
 	if err != nil {
 		return nil, "", errors.New("failed to generate token")
 	}
 
+	m.config.Metrics.IncLogin()
+
 	return user, token, nil
 }
 
-// GetUserByEmail finds a user by email
+// GetUserByEmail finds a user by email, returning ErrUserNotFound for
+// soft-deleted accounts
 func (m *Manager) GetUserByEmail(email string) (*User, error) {
-	users, err := m.db.Find(m.config.DatabaseName, map[string]any{"email": email})
+	return m.GetUserByEmailContext(context.Background(), email)
+}
+
+// GetUserByEmailContext is like GetUserByEmail but runs under ctx
+func (m *Manager) GetUserByEmailContext(ctx context.Context, email string) (*User, error) {
+	if m.config.UserStore != nil {
+		return m.config.UserStore.GetUserByEmail(email)
+	}
+
+	users, err := m.findByEmail(ctx, email)
 	if err != nil {
 		return nil, err
 	}
 
 	if len(users) == 0 {
-		return nil, errors.New("user not found")
+		return nil, ErrUserNotFound
 	}
 
 	user := &User{}
@@ -128,9 +363,127 @@ func (m *Manager) GetUserByEmail(email string) (*User, error) {
 	if password, ok := users[0]["password"].(string); ok {
 		user.Password = password
 	}
+	if verified, ok := users[0]["email_verified"].(bool); ok {
+		user.EmailVerified = verified
+	}
 	if custom, ok := users[0]["custom"].(map[string]interface{}); ok {
 		user.Custom = custom
 	}
+	if role, ok := users[0]["role"].(string); ok {
+		user.Role = role
+	}
+	if deletedAt, ok := users[0]["deleted_at"].(primitive.DateTime); ok {
+		t := deletedAt.Time()
+		user.DeletedAt = &t
+	}
+	switch version := users[0]["token_version"].(type) {
+	case int32:
+		user.TokenVersion = int(version)
+	case int64:
+		user.TokenVersion = int(version)
+	}
 
 	return user, nil
-}
\ No newline at end of file
+}
+
+// insertUser saves a new user to the UserStore or the users collection and
+// returns its ID
+func (m *Manager) insertUser(ctx context.Context, user *User) (string, error) {
+	if m.config.UserStore != nil {
+		return m.config.UserStore.CreateUser(user)
+	}
+	return m.db.InsertOneContext(ctx, m.config.DatabaseName, user)
+}
+
+// findByEmail looks up users by email, ignoring case when EmailIndex is
+// EmailIndexCaseInsensitive
+func (m *Manager) findByEmail(ctx context.Context, email string) ([]map[string]any, error) {
+	filter := map[string]any{"email": email, "deleted_at": notDeleted}
+	if m.config.EmailIndex != EmailIndexCaseInsensitive {
+		return m.db.FindContext(ctx, m.config.DatabaseName, filter)
+	}
+	return m.db.FindContext(ctx, m.config.DatabaseName, filter, options.Find().SetCollation(emailCollation).SetLimit(1))
+}
+
+// emailDomainAllowed checks the email's domain against the configured lists
+func (m *Manager) emailDomainAllowed(email string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		// Malformed emails are left to the usual validation
+		return len(m.config.AllowedEmailDomains) == 0
+	}
+	domain := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(email[at+1:])), ".")
+
+	if matchesDomain(domain, m.config.BlockedEmailDomains) {
+		return false
+	}
+	return len(m.config.AllowedEmailDomains) == 0 || matchesDomain(domain, m.config.AllowedEmailDomains)
+}
+
+// matchesDomain reports whether domain is one of domains or a subdomain of one
+func matchesDomain(domain string, domains []string) bool {
+	for _, d := range domains {
+		if domain == d || strings.HasSuffix(domain, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeDomains lowercases domains and strips a leading "@" or "."
+func normalizeDomains(domains []string) []string {
+	normalized := make([]string, 0, len(domains))
+	for _, d := range domains {
+		d = strings.TrimLeft(strings.ToLower(strings.TrimSpace(d)), "@.")
+		if d != "" {
+			normalized = append(normalized, d)
+		}
+	}
+	return normalized
+}
+
+// withDefaultCustom returns DefaultCustom overlaid with custom, leaving both untouched
+func (m *Manager) withDefaultCustom(custom map[string]any) map[string]any {
+	if len(m.config.DefaultCustom) == 0 {
+		return custom
+	}
+	merged := make(map[string]any, len(m.config.DefaultCustom)+len(custom))
+	for k, v := range m.config.DefaultCustom {
+		merged[k] = v
+	}
+	for k, v := range custom {
+		merged[k] = v
+	}
+	return merged
+}
+
+// validateCustom runs the configured CustomValidator, if any
+func (m *Manager) validateCustom(custom map[string]any) error {
+	if m.config.CustomValidator == nil {
+		return nil
+	}
+	return m.config.CustomValidator(custom)
+}
+
+
+// setDefaults fills empty collection names
+func (c *Collections) setDefaults() {
+	if c.RevokedTokens == "" {
+		c.RevokedTokens = "revoked_tokens"
+	}
+	if c.PasswordResets == "" {
+		c.PasswordResets = "password_resets"
+	}
+	if c.EmailVerifications == "" {
+		c.EmailVerifications = "email_verifications"
+	}
+	if c.ResetRequests == "" {
+		c.ResetRequests = "password_reset_requests"
+	}
+	if c.AuditLog == "" {
+		c.AuditLog = "audit_log"
+	}
+	if c.Invites == "" {
+		c.Invites = "invites"
+	}
+}
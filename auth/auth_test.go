@@ -0,0 +1,423 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestNewResponseShapeAndDelivery(t *testing.T) {
+	tests := []struct {
+		name     string
+		shape    string
+		delivery string
+		wantErr  bool
+	}{
+		{"defaults", "", "", false},
+		{"user only in body", ShapeUserOnly, DeliverBody, true},
+		{"user only by default delivery", ShapeUserOnly, "", true},
+		{"user only with cookie", ShapeUserOnly, DeliverCookie, false},
+		{"user only with both", ShapeUserOnly, DeliverBoth, false},
+		{"token only in body", ShapeTokenOnly, DeliverBody, false},
+		{"unknown shape", "everything", "", true},
+		{"unknown delivery", "", "pigeon", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				Secret:        "test-secret",
+				ResponseShape: tt.shape,
+				TokenDelivery: tt.delivery,
+			}
+			_, err := New(config, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewWithoutDatabase(t *testing.T) {
+	// EnsureIndexes is on by default, but there is no database to index
+	m, err := New(&Config{Secret: "test-secret"}, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if _, ok := m.config.AuditLogger.(NoopAuditLogger); !ok {
+		t.Fatalf("AuditLogger = %T, want NoopAuditLogger", m.config.AuditLogger)
+	}
+	if err := m.Audit(AuditPasswordChanged, "user-1", "203.0.113.7"); err != nil {
+		t.Fatalf("Audit: %v", err)
+	}
+}
+
+func TestSignupLookupFailure(t *testing.T) {
+	m := newTestManager(t, &Config{})
+	mustSignup(t, m, "taken@example.com", "correct horse battery")
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		email      string
+		wantLookup bool
+		wantErr    bool
+	}{
+		{"new email", context.Background(), "new@example.com", false, false},
+		{"existing email", context.Background(), "taken@example.com", false, true},
+		{"lookup fails", cancelled, "other@example.com", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := m.SignupContext(tt.ctx, SignupRequest{Email: tt.email, Password: "correct horse battery"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Signup error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := errors.Is(err, ErrLookupFailed); got != tt.wantLookup {
+				t.Fatalf("errors.Is(%v, ErrLookupFailed) = %v, want %v", err, got, tt.wantLookup)
+			}
+		})
+	}
+
+	// The failed lookup must not have let the signup through
+	if _, err := m.GetUserByEmail("other@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("GetUserByEmail after failed lookup = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestCustomValidator(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	m := newOfflineManager(t, &Config{
+		CustomValidator: func(custom map[string]any) error {
+			if plan, _ := custom["plan"].(string); plan != "free" && plan != "pro" {
+				return FieldErrors{"plan": "must be free or pro"}
+			}
+			return nil
+		},
+	})
+	router := gin.New()
+	router.POST("/signup", m.SignupHandler())
+
+	tests := []struct {
+		name       string
+		custom     string
+		wantFields map[string]string
+	}{
+		{"valid", `{"plan":"pro"}`, nil},
+		{"unknown plan", `{"plan":"gold"}`, map[string]string{"plan": "must be free or pro"}},
+		{"missing plan", `{}`, map[string]string{"plan": "must be free or pro"}},
+		{"no custom", `null`, map[string]string{"plan": "must be free or pro"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var custom map[string]any
+			if err := json.Unmarshal([]byte(tt.custom), &custom); err != nil {
+				t.Fatalf("custom: %v", err)
+			}
+			err := m.validateCustom(custom)
+			if (err != nil) != (tt.wantFields != nil) {
+				t.Fatalf("validateCustom error = %v, want fields %v", err, tt.wantFields)
+			}
+			if tt.wantFields == nil {
+				return
+			}
+
+			// Rejected before the database is touched
+			body := `{"email":"a@example.com","password":"correct horse battery","custom":` + tt.custom + `}`
+			req := httptest.NewRequest("POST", "/signup", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			var resp ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode %q: %v", w.Body.String(), err)
+			}
+			if w.Code != 400 || resp.Error.Code != CodeValidationFailed {
+				t.Fatalf("response = %d %s, want 400 %s", w.Code, w.Body.String(), CodeValidationFailed)
+			}
+			if len(resp.Error.Fields) != len(tt.wantFields) || resp.Error.Fields["plan"] != tt.wantFields["plan"] {
+				t.Fatalf("fields = %v, want %v", resp.Error.Fields, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestAutoLoginAfterSignup(t *testing.T) {
+	tests := []struct {
+		name      string
+		autoLogin *bool
+		wantToken bool
+	}{
+		{"default", nil, true},
+		{"enabled", Bool(true), true},
+		{"disabled", Bool(false), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t, &Config{AutoLoginAfterSignup: tt.autoLogin})
+			user, token, err := m.Signup(SignupRequest{Email: "auto@example.com", Password: "correct horse battery"})
+			if err != nil {
+				t.Fatalf("Signup: %v", err)
+			}
+			if user == nil || user.ID == "" {
+				t.Fatalf("Signup returned user %+v", user)
+			}
+			if (token != "") != tt.wantToken {
+				t.Fatalf("token = %q, want token %v", token, tt.wantToken)
+			}
+			if tt.wantToken {
+				if id, err := m.ValidateToken(token); err != nil || id != user.ID {
+					t.Fatalf("ValidateToken = %q, %v, want %q", id, err, user.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestLoginRememberMe(t *testing.T) {
+	m := newTestManager(t, &Config{TokenExpiry: 30, RememberMeExpiry: 7 * 24 * 60})
+	mustSignup(t, m, "remember@example.com", "correct horse battery")
+
+	tests := []struct {
+		name       string
+		rememberMe bool
+		want       time.Duration
+	}{
+		{"default", false, 30 * time.Minute},
+		{"remember me", true, 7 * 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, token, err := m.Login(LoginRequest{Email: "remember@example.com", Password: "correct horse battery", RememberMe: tt.rememberMe})
+			if err != nil {
+				t.Fatalf("Login: %v", err)
+			}
+
+			claims := jwt.MapClaims{}
+			if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			exp, _ := claims["exp"].(float64)
+			iat, _ := claims["iat"].(float64)
+			if got := time.Duration(exp-iat) * time.Second; got != tt.want {
+				t.Fatalf("lifetime = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollectionsDefaults(t *testing.T) {
+	defaults := Collections{
+		RevokedTokens:      "revoked_tokens",
+		PasswordResets:     "password_resets",
+		EmailVerifications: "email_verifications",
+		ResetRequests:      "password_reset_requests",
+		AuditLog:           "audit_log",
+		Invites:            "invites",
+	}
+	renamed := defaults
+	renamed.RevokedTokens = "app_revoked_tokens"
+	renamed.AuditLog = "app_audit"
+
+	tests := []struct {
+		name        string
+		collections Collections
+		want        Collections
+	}{
+		{"unset", Collections{}, defaults},
+		{"partly set", Collections{RevokedTokens: "app_revoked_tokens", AuditLog: "app_audit"}, renamed},
+		{"all set", defaults, defaults},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newOfflineManager(t, &Config{Collections: tt.collections})
+			if m.config.Collections != tt.want {
+				t.Fatalf("Collections = %+v, want %+v", m.config.Collections, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollectionsRenamed(t *testing.T) {
+	m := newTestManager(t, &Config{Collections: Collections{RevokedTokens: "app_revoked_tokens"}})
+
+	if err := m.RevokeToken("jti-1", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+
+	tests := []struct {
+		collection string
+		want       int64
+	}{
+		{"app_revoked_tokens", 1},
+		{"revoked_tokens", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.collection, func(t *testing.T) {
+			count, err := m.db.Collection(tt.collection).CountDocuments(t.Context(), bson.M{})
+			if err != nil {
+				t.Fatalf("count: %v", err)
+			}
+			if count != tt.want {
+				t.Fatalf("%d documents in %s, want %d", count, tt.collection, tt.want)
+			}
+		})
+	}
+}
+
+func TestContextCancelled(t *testing.T) {
+	m := newTestManager(t, &Config{})
+	user := mustSignup(t, m, "ctx@example.com", "correct horse battery")
+	token, err := m.GenerateToken(user.ID)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		call func(ctx context.Context) error
+	}{
+		{"GetUserByIDContext", func(ctx context.Context) error {
+			_, err := m.GetUserByIDContext(ctx, user.ID)
+			return err
+		}},
+		{"GetUserPublicContext", func(ctx context.Context) error {
+			_, err := m.GetUserPublicContext(ctx, user.ID)
+			return err
+		}},
+		{"LoginContext", func(ctx context.Context) error {
+			_, _, err := m.LoginContext(ctx, LoginRequest{Email: user.Email, Password: "correct horse battery"})
+			return err
+		}},
+		{"ParseTokenContext", func(ctx context.Context) error {
+			_, err := m.ParseTokenContext(ctx, token)
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(t.Context())
+			cancel()
+			if err := tt.call(ctx); !errors.Is(err, context.Canceled) {
+				t.Fatalf("%s error = %v, want %v", tt.name, err, context.Canceled)
+			}
+		})
+	}
+}
+
+func TestDefaultCustom(t *testing.T) {
+	tests := []struct {
+		name     string
+		defaults map[string]any
+		custom   map[string]any
+		want     map[string]any
+	}{
+		{"no defaults", nil, map[string]any{"plan": "pro"}, map[string]any{"plan": "pro"}},
+		{"no defaults, no custom", nil, nil, nil},
+		{"defaults only", map[string]any{"plan": "free", "theme": "light"}, nil, map[string]any{"plan": "free", "theme": "light"}},
+		{"request wins", map[string]any{"plan": "free", "theme": "light"}, map[string]any{"plan": "pro"}, map[string]any{"plan": "pro", "theme": "light"}},
+		{"extra request fields", map[string]any{"plan": "free"}, map[string]any{"name": "Ada"}, map[string]any{"plan": "free", "name": "Ada"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newOfflineManager(t, &Config{DefaultCustom: tt.defaults})
+			before := fmt.Sprint(tt.defaults, tt.custom)
+
+			got := m.withDefaultCustom(tt.custom)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) || (got == nil) != (tt.want == nil) {
+				t.Fatalf("withDefaultCustom = %v, want %v", got, tt.want)
+			}
+			if after := fmt.Sprint(tt.defaults, tt.custom); after != before {
+				t.Fatalf("inputs changed from %s to %s", before, after)
+			}
+		})
+	}
+}
+
+func TestSignupCustomFieldsReserved(t *testing.T) {
+	tests := []struct {
+		name    string
+		fields  []string
+		wantErr bool
+	}{
+		{"custom fields", []string{"name", "company"}, false},
+		{"none", nil, false},
+		{"role", []string{"name", "role"}, true},
+		{"password", []string{"password"}, true},
+		{"email verified", []string{"email_verified"}, true},
+		{"mongo id", []string{"_id"}, true},
+		{"case insensitive", []string{"Role"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Secret: "test-secret", SignupCustomFields: tt.fields}
+			if _, err := New(config, nil); (err != nil) != tt.wantErr {
+				t.Fatalf("New error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEmailDomainAllowed(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		blocked []string
+		email   string
+		want    bool
+	}{
+		{"no lists", nil, nil, "a@example.com", true},
+		{"allowed domain", []string{"example.com"}, nil, "a@example.com", true},
+		{"allowed subdomain", []string{"example.com"}, nil, "a@eu.example.com", true},
+		{"other domain", []string{"example.com"}, nil, "a@other.com", false},
+		{"suffix without dot", []string{"example.com"}, nil, "a@badexample.com", false},
+		{"case and spacing", []string{" @Example.COM "}, nil, "a@EXAMPLE.com.", true},
+		{"leading dot", []string{".example.com"}, nil, "a@example.com", true},
+		{"blocked domain", nil, []string{"spam.com"}, "a@spam.com", false},
+		{"blocked subdomain", nil, []string{"spam.com"}, "a@mail.spam.com", false},
+		{"blocked wins", []string{"example.com"}, []string{"eu.example.com"}, "a@eu.example.com", false},
+		{"malformed, no lists", nil, nil, "not-an-email", true},
+		{"malformed, allow list", []string{"example.com"}, nil, "not-an-email", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newOfflineManager(t, &Config{AllowedEmailDomains: tt.allowed, BlockedEmailDomains: tt.blocked})
+			if got := m.emailDomainAllowed(tt.email); got != tt.want {
+				t.Fatalf("emailDomainAllowed(%q) = %v, want %v", tt.email, got, tt.want)
+			}
+
+			// Rejected domains fail before Signup touches the database
+			if !tt.want && strings.Contains(tt.email, "@") {
+				_, _, err := m.Signup(SignupRequest{Email: tt.email, Password: "correct horse battery"})
+				if !errors.Is(err, ErrEmailDomainNotAllowed) {
+					t.Fatalf("Signup error = %v, want %v", err, ErrEmailDomainNotAllowed)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UserCache holds users loaded by GetUserByID, e.g. backed by Redis to share
+// entries between instances. Implementations must be safe for concurrent use,
+// and Get must return a user the caller may modify without changing the entry.
+type UserCache interface {
+	Get(userID string) (*User, bool)
+	Set(userID string, user *User)
+	Delete(userID string)
+}
+
+// MemoryUserCache is an in-memory LRU UserCache whose entries expire after a TTL.
+// Entries are per process; other instances only see changes once they expire.
+type MemoryUserCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	order   *list.List // most recently used first
+	entries map[string]*list.Element
+}
+
+type cachedUser struct {
+	userID  string
+	user    User
+	expires time.Time
+}
+
+// NewMemoryUserCache returns a cache holding up to size users for ttl each
+func NewMemoryUserCache(size int, ttl time.Duration) *MemoryUserCache {
+	return &MemoryUserCache{
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Get returns a copy of the cached user, if present and not expired
+func (c *MemoryUserCache) Get(userID string) (*User, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[userID]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*cachedUser)
+	if time.Now().After(entry.expires) {
+		c.remove(elem)
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	return copyUser(&entry.user), true
+}
+
+// Set stores a copy of user, evicting the least recently used entry when full
+func (c *MemoryUserCache) Set(userID string, user *User) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &cachedUser{userID: userID, user: *copyUser(user), expires: time.Now().Add(c.ttl)}
+	if elem, ok := c.entries[userID]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[userID] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+// Delete drops the user from the cache
+func (c *MemoryUserCache) Delete(userID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[userID]; ok {
+		c.remove(elem)
+	}
+}
+
+func (c *MemoryUserCache) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*cachedUser).userID)
+}
+
+// copyUser returns a copy of user sharing no maps, slices or pointers with it,
+// so callers can't change cached entries
+func copyUser(user *User) *User {
+	c := *user
+	if user.Custom != nil {
+		c.Custom = copyMap(user.Custom)
+	}
+	if user.DeletedAt != nil {
+		deletedAt := *user.DeletedAt
+		c.DeletedAt = &deletedAt
+	}
+	if user.PasswordHistory != nil {
+		c.PasswordHistory = append([]string(nil), user.PasswordHistory...)
+	}
+	return &c
+}
+
+func copyMap(m map[string]any) map[string]any {
+	c := make(map[string]any, len(m))
+	for k, v := range m {
+		c[k] = copyValue(v)
+	}
+	return c
+}
+
+// copyValue deep-copies the container types custom data decodes into
+func copyValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		return copyMap(v)
+	case primitive.M:
+		return primitive.M(copyMap(v))
+	case primitive.D:
+		c := make(primitive.D, len(v))
+		for i, e := range v {
+			c[i] = primitive.E{Key: e.Key, Value: copyValue(e.Value)}
+		}
+		return c
+	case []any:
+		c := make([]any, len(v))
+		for i, e := range v {
+			c[i] = copyValue(e)
+		}
+		return c
+	case primitive.A:
+		c := make(primitive.A, len(v))
+		for i, e := range v {
+			c[i] = copyValue(e)
+		}
+		return c
+	default:
+		return v
+	}
+}
+
+// cacheGeneration returns the invalidation count to pass to cacheUser
+func (m *Manager) cacheGeneration() uint64 {
+	m.cacheMu.Lock()
+	defer m.cacheMu.Unlock()
+
+	return m.cacheGen
+}
+
+// cacheUser stores a user loaded from the database, unless an invalidation
+// happened since gen was taken: the load may then have read the old document
+func (m *Manager) cacheUser(gen uint64, user *User) {
+	if m.config.UserCache == nil {
+		return
+	}
+
+	m.cacheMu.Lock()
+	defer m.cacheMu.Unlock()
+
+	if m.cacheGen == gen {
+		m.config.UserCache.Set(user.ID, copyUser(user))
+	}
+}
+
+// invalidateUser drops the user from the configured cache. Call it after the
+// write, so a concurrent load either sees the change or isn't cached.
+func (m *Manager) invalidateUser(userID string) {
+	if m.config.UserCache == nil {
+		return
+	}
+
+	m.cacheMu.Lock()
+	defer m.cacheMu.Unlock()
+
+	m.cacheGen++
+	m.config.UserCache.Delete(userID)
+}
@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMemoryUserCacheCopies(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(u *User)
+	}{
+		{"top-level field", func(u *User) { u.Email = "changed@example.com" }},
+		{"custom key", func(u *User) { u.Custom["plan"] = "changed" }},
+		{"nested map", func(u *User) { u.Custom["address"].(map[string]any)["city"] = "changed" }},
+		{"nested bson map", func(u *User) { u.Custom["prefs"].(primitive.M)["theme"] = "changed" }},
+		{"nested slice", func(u *User) { u.Custom["tags"].([]any)[0] = "changed" }},
+		{"nested bson array", func(u *User) { u.Custom["scores"].(primitive.A)[0] = 0 }},
+		{"nested bson document", func(u *User) { u.Custom["meta"].(primitive.D)[0].Value = "changed" }},
+		{"deleted at", func(u *User) { *u.DeletedAt = time.Time{} }},
+		{"password history", func(u *User) { u.PasswordHistory[0] = "changed" }},
+	}
+
+	newUser := func() *User {
+		deletedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		return &User{
+			ID:    "u1",
+			Email: "user@example.com",
+			Custom: map[string]any{
+				"plan":    "pro",
+				"address": map[string]any{"city": "Boston"},
+				"prefs":   primitive.M{"theme": "dark"},
+				"tags":    []any{"a"},
+				"scores":  primitive.A{1},
+				"meta":    primitive.D{{Key: "source", Value: "web"}},
+			},
+			DeletedAt:       &deletedAt,
+			PasswordHistory: []string{"hash"},
+		}
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewMemoryUserCache(10, time.Minute)
+
+			// Changing the stored user after Set
+			stored := newUser()
+			cache.Set("u1", stored)
+			tt.mutate(stored)
+			assertUnchanged(t, cache, newUser())
+
+			// Changing a user returned by Get
+			got, _ := cache.Get("u1")
+			tt.mutate(got)
+			assertUnchanged(t, cache, newUser())
+		})
+	}
+}
+
+func assertUnchanged(t *testing.T, cache *MemoryUserCache, want *User) {
+	t.Helper()
+
+	got, ok := cache.Get("u1")
+	if !ok {
+		t.Fatal("user missing from cache")
+	}
+	if got.Email != want.Email ||
+		got.Custom["plan"] != want.Custom["plan"] ||
+		got.Custom["address"].(map[string]any)["city"] != "Boston" ||
+		got.Custom["prefs"].(primitive.M)["theme"] != "dark" ||
+		got.Custom["tags"].([]any)[0] != "a" ||
+		got.Custom["scores"].(primitive.A)[0] != 1 ||
+		got.Custom["meta"].(primitive.D)[0].Value != "web" ||
+		!got.DeletedAt.Equal(*want.DeletedAt) ||
+		got.PasswordHistory[0] != "hash" {
+		t.Fatalf("cached user changed: %+v", got)
+	}
+}
+
+func TestMemoryUserCacheEviction(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		ttl     time.Duration
+		ids     []string
+		lookup  string
+		wantHit bool
+	}{
+		{"hit", 2, time.Minute, []string{"a"}, "a", true},
+		{"miss", 2, time.Minute, []string{"a"}, "b", false},
+		{"expired", 2, -time.Second, []string{"a"}, "a", false},
+		{"least recently used evicted", 2, time.Minute, []string{"a", "b", "c"}, "a", false},
+		{"newest kept", 2, time.Minute, []string{"a", "b", "c"}, "c", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewMemoryUserCache(tt.size, tt.ttl)
+			for _, id := range tt.ids {
+				cache.Set(id, &User{ID: id})
+			}
+			if _, ok := cache.Get(tt.lookup); ok != tt.wantHit {
+				t.Fatalf("Get(%q) hit = %v, want %v", tt.lookup, ok, tt.wantHit)
+			}
+		})
+	}
+}
+
+func TestCacheUserSkipsStaleLoads(t *testing.T) {
+	tests := []struct {
+		name       string
+		invalidate string // user invalidated between taking the generation and caching
+		wantCached bool
+	}{
+		{"no invalidation", "", true},
+		{"same user invalidated", "u1", false},
+		{"other user invalidated", "u2", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewMemoryUserCache(10, time.Minute)
+			m := newOfflineManager(t, &Config{UserCache: cache})
+
+			gen := m.cacheGeneration()
+			if tt.invalidate != "" {
+				m.invalidateUser(tt.invalidate)
+			}
+			m.cacheUser(gen, &User{ID: "u1"})
+
+			if _, ok := cache.Get("u1"); ok != tt.wantCached {
+				t.Fatalf("cached = %v, want %v", ok, tt.wantCached)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Headers accepted for Config.ClientIPHeader
+const (
+	HeaderForwardedFor = "X-Forwarded-For"
+	HeaderRealIP       = "X-Real-IP"
+)
+
+// parseTrustedProxies parses IPs and CIDR ranges into prefixes
+func parseTrustedProxies(proxies []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(proxies))
+	for _, proxy := range proxies {
+		if strings.Contains(proxy, "/") {
+			prefix, err := netip.ParsePrefix(proxy)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// ClientIP returns the address of the client that sent the request. The
+// ClientIPHeader is only read when the direct peer is one of TrustedProxies;
+// X-Forwarded-For is walked from the right, skipping trusted proxies, so
+// addresses a client prepends itself are never used. Rate limiting and the
+// audit log use it.
+func (m *Manager) ClientIP(c *gin.Context) string {
+	remote := c.RemoteIP()
+	if !m.trustedProxy(remote) {
+		return remote
+	}
+
+	// Compared canonicalized, which turns "X-Real-IP" into "X-Real-Ip"
+	header := http.CanonicalHeaderKey(m.config.ClientIPHeader)
+	if header == http.CanonicalHeaderKey(HeaderRealIP) {
+		if addr, err := netip.ParseAddr(strings.TrimSpace(c.GetHeader(header))); err == nil {
+			return addr.Unmap().String()
+		}
+		return remote
+	}
+
+	hops := strings.Split(strings.Join(c.Request.Header.Values(header), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		addr, err := netip.ParseAddr(hop)
+		if err != nil {
+			// A malformed entry can't be attributed, stop at the last good hop
+			break
+		}
+		if !m.trustedProxy(hop) {
+			return addr.Unmap().String()
+		}
+		remote = addr.Unmap().String()
+	}
+	return remote
+}
+
+// trustedProxy reports whether ip is within TrustedProxies
+func (m *Manager) trustedProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range m.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestClientIP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	proxies := []string{"10.0.0.0/8", "192.0.2.10"}
+
+	tests := []struct {
+		name    string
+		proxies []string
+		header  string
+		remote  string
+		values  map[string]string
+		want    string
+	}{
+		{"no proxies", nil, "", "203.0.113.5:1234", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "203.0.113.5"},
+		{"untrusted peer", proxies, "", "203.0.113.5:1234", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "203.0.113.5"},
+		{"trusted peer", proxies, "", "10.1.2.3:1234", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "198.51.100.1"},
+		{"trusted single IP", proxies, "", "192.0.2.10:1234", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "198.51.100.1"},
+		{"spoofed entry ignored", proxies, "", "10.1.2.3:1234", map[string]string{"X-Forwarded-For": "1.1.1.1, 198.51.100.1"}, "198.51.100.1"},
+		{"proxy chain", proxies, "", "10.1.2.3:1234", map[string]string{"X-Forwarded-For": "198.51.100.1, 10.9.9.9"}, "198.51.100.1"},
+		{"only proxies", proxies, "", "10.1.2.3:1234", map[string]string{"X-Forwarded-For": "10.9.9.9"}, "10.9.9.9"},
+		{"malformed hop", proxies, "", "10.1.2.3:1234", map[string]string{"X-Forwarded-For": "198.51.100.1, bogus"}, "10.1.2.3"},
+		{"no header", proxies, "", "10.1.2.3:1234", nil, "10.1.2.3"},
+		{"mapped IPv4", proxies, "", "10.1.2.3:1234", map[string]string{"X-Forwarded-For": "::ffff:198.51.100.1"}, "198.51.100.1"},
+		{"real IP", proxies, HeaderRealIP, "10.1.2.3:1234", map[string]string{"X-Real-IP": "198.51.100.7", "X-Forwarded-For": "198.51.100.1"}, "198.51.100.7"},
+		{"real IP, lowercase option", proxies, "x-real-ip", "10.1.2.3:1234", map[string]string{"X-Real-IP": "198.51.100.7"}, "198.51.100.7"},
+		{"real IP, untrusted peer", proxies, HeaderRealIP, "203.0.113.5:1234", map[string]string{"X-Real-IP": "198.51.100.7"}, "203.0.113.5"},
+		{"real IP, malformed", proxies, HeaderRealIP, "10.1.2.3:1234", map[string]string{"X-Real-IP": "bogus"}, "10.1.2.3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newOfflineManager(t, &Config{TrustedProxies: tt.proxies, ClientIPHeader: tt.header})
+
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/", nil)
+			c.Request.RemoteAddr = tt.remote
+			for key, value := range tt.values {
+				c.Request.Header.Set(key, value)
+			}
+
+			if got := m.ClientIP(c); got != tt.want {
+				t.Fatalf("ClientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIPConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *Config
+		wantErr bool
+	}{
+		{"defaults", &Config{}, false},
+		{"IPs and ranges", &Config{TrustedProxies: []string{"10.0.0.1", "10.0.0.0/8", "::1", "fd00::/8"}}, false},
+		{"invalid IP", &Config{TrustedProxies: []string{"10.0.0.300"}}, true},
+		{"invalid range", &Config{TrustedProxies: []string{"10.0.0.0/33"}}, true},
+		{"X-Forwarded-For", &Config{ClientIPHeader: HeaderForwardedFor}, false},
+		{"X-Real-IP", &Config{ClientIPHeader: HeaderRealIP}, false},
+		{"unsupported header", &Config{ClientIPHeader: "CF-Connecting-IP"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.Secret = "test-secret"
+			if _, err := New(tt.config, nil); (err != nil) != tt.wantErr {
+				t.Fatalf("New error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
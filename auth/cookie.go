@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Token delivery modes for Config.TokenDelivery
+const (
+	DeliverBody   = "body"
+	DeliverCookie = "cookie"
+	DeliverBoth   = "both"
+)
+
+// CookieConfig configures the token cookie used when Config.TokenDelivery is
+// DeliverCookie or DeliverBoth. The cookie is always HttpOnly.
+type CookieConfig struct {
+	Name     string        // default: "auth_token"
+	Domain   string        // default: the request host only
+	Path     string        // default: "/"
+	Secure   *bool         // default: true; set auth.Bool(false) for plain HTTP in development
+	SameSite http.SameSite // default: http.SameSiteStrictMode
+
+	// CSRFHeader must be sent with POST, PUT, PATCH and DELETE requests that
+	// authenticate with the cookie. Browsers only let same-origin pages, or
+	// origins your CORS policy allows, set custom headers. Default: "X-Requested-With".
+	CSRFHeader string
+
+	// DisableCSRFCheck skips the CSRF header check, e.g. when another CSRF
+	// middleware already protects the routes
+	DisableCSRFCheck bool
+}
+
+// setDefaults fills in the default cookie settings
+func (c *CookieConfig) setDefaults() {
+	if c.Name == "" {
+		c.Name = "auth_token"
+	}
+	if c.Path == "" {
+		c.Path = "/"
+	}
+	if c.Secure == nil {
+		c.Secure = Bool(true)
+	}
+	if c.SameSite == 0 {
+		c.SameSite = http.SameSiteStrictMode
+	}
+	if c.CSRFHeader == "" {
+		c.CSRFHeader = "X-Requested-With"
+	}
+}
+
+// usesCookie reports whether tokens are delivered in a cookie
+func (m *Manager) usesCookie() bool {
+	return m.config.TokenDelivery == DeliverCookie || m.config.TokenDelivery == DeliverBoth
+}
+
+// deliverToken sets the token cookie if configured and returns the token to
+// put in the response body, which is empty in DeliverCookie mode
+func (m *Manager) deliverToken(c *gin.Context, token string, rememberMe bool) string {
+	if token == "" || !m.usesCookie() {
+		return token
+	}
+
+	expiry := m.config.TokenExpiry
+	if rememberMe {
+		expiry = m.config.RememberMeExpiry
+	}
+	m.setTokenCookie(c, token, time.Duration(expiry)*time.Minute)
+
+	if m.config.TokenDelivery == DeliverCookie {
+		return ""
+	}
+	return token
+}
+
+// ClearTokenCookie removes the token cookie, e.g. in a logout handler.
+// It does nothing unless tokens are delivered in a cookie.
+func (m *Manager) ClearTokenCookie(c *gin.Context) {
+	if m.usesCookie() {
+		m.setTokenCookie(c, "", -time.Second)
+	}
+}
+
+// Helper method
+func (m *Manager) setTokenCookie(c *gin.Context, value string, maxAge time.Duration) {
+	cookie := m.config.Cookie
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     cookie.Name,
+		Value:    value,
+		Domain:   cookie.Domain,
+		Path:     cookie.Path,
+		MaxAge:   int(maxAge / time.Second),
+		Secure:   *cookie.Secure,
+		HttpOnly: true,
+		SameSite: cookie.SameSite,
+	})
+}
+
+// tokenFromCookie returns the token cookie, if cookies are in use
+func (m *Manager) tokenFromCookie(c *gin.Context) string {
+	if !m.usesCookie() {
+		return ""
+	}
+	token, err := c.Cookie(m.config.Cookie.Name)
+	if err != nil {
+		return ""
+	}
+	return token
+}
+
+// csrfHeaderMissing reports whether a request authenticated by the cookie
+// changes state without the CSRF header
+func (m *Manager) csrfHeaderMissing(c *gin.Context) bool {
+	if m.config.Cookie.DisableCSRFCheck {
+		return false
+	}
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return false
+	}
+	return c.GetHeader(m.config.Cookie.CSRFHeader) == ""
+}
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"math"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CustomValue returns the custom field at key. Nested fields are addressed
+// with dots, e.g. "address.city".
+func (u *User) CustomValue(key string) (any, bool) {
+	var current any = u.Custom
+	for _, part := range strings.Split(key, ".") {
+		switch doc := current.(type) {
+		case map[string]any:
+			value, ok := doc[part]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case primitive.M:
+			value, ok := doc[part]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case primitive.D:
+			found := false
+			for _, elem := range doc {
+				if elem.Key == part {
+					current, found = elem.Value, true
+					break
+				}
+			}
+			if !found {
+				return nil, false
+			}
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// CustomString returns the custom field at key if it is a string
+func (u *User) CustomString(key string) (string, bool) {
+	value, _ := u.CustomValue(key)
+	s, ok := value.(string)
+	return s, ok
+}
+
+// CustomInt returns the custom field at key if it is a whole number.
+// JSON numbers arrive as float64 and BSON integers as int32/int64; all are accepted.
+func (u *User) CustomInt(key string) (int64, bool) {
+	value, _ := u.CustomValue(key)
+	switch n := value.(type) {
+	case int:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case float64:
+		if n != math.Trunc(n) || n < math.MinInt64 || n >= math.MaxInt64 {
+			return 0, false
+		}
+		return int64(n), true
+	}
+	return 0, false
+}
+
+// CustomFloat returns the custom field at key if it is a number
+func (u *User) CustomFloat(key string) (float64, bool) {
+	value, _ := u.CustomValue(key)
+	switch n := value.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// CustomBool returns the custom field at key if it is a boolean
+func (u *User) CustomBool(key string) (bool, bool) {
+	value, _ := u.CustomValue(key)
+	b, ok := value.(bool)
+	return b, ok
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"math"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCustomAccessors(t *testing.T) {
+	user := &User{Custom: map[string]any{
+		"name":     "Ada",
+		"age":      float64(36),
+		"ratio":    1.5,
+		"count32":  int32(7),
+		"count64":  int64(1) << 40,
+		"count":    3,
+		"huge":     math.MaxFloat64,
+		"admin":    true,
+		"address":  primitive.M{"city": "London", "geo": primitive.D{{Key: "lat", Value: 51.5}}},
+		"settings": map[string]any{"theme": "dark"},
+	}}
+
+	tests := []struct {
+		name   string
+		get    func(key string) (any, bool)
+		key    string
+		want   any
+		wantOK bool
+	}{
+		{"string", accessor(user.CustomString), "name", "Ada", true},
+		{"string, wrong type", accessor(user.CustomString), "age", "", false},
+		{"string, missing", accessor(user.CustomString), "nickname", "", false},
+		{"string, nested map", accessor(user.CustomString), "settings.theme", "dark", true},
+		{"string, nested primitive.M", accessor(user.CustomString), "address.city", "London", true},
+		{"string, through a scalar", accessor(user.CustomString), "name.first", "", false},
+
+		{"int from JSON number", accessor(user.CustomInt), "age", int64(36), true},
+		{"int from int32", accessor(user.CustomInt), "count32", int64(7), true},
+		{"int from int64", accessor(user.CustomInt), "count64", int64(1) << 40, true},
+		{"int from int", accessor(user.CustomInt), "count", int64(3), true},
+		{"int, fractional", accessor(user.CustomInt), "ratio", int64(0), false},
+		{"int, out of range", accessor(user.CustomInt), "huge", int64(0), false},
+		{"int, wrong type", accessor(user.CustomInt), "name", int64(0), false},
+
+		{"float", accessor(user.CustomFloat), "ratio", 1.5, true},
+		{"float from int32", accessor(user.CustomFloat), "count32", 7.0, true},
+		{"float, nested primitive.D", accessor(user.CustomFloat), "address.geo.lat", 51.5, true},
+		{"float, wrong type", accessor(user.CustomFloat), "admin", 0.0, false},
+
+		{"bool", accessor(user.CustomBool), "admin", true, true},
+		{"bool, wrong type", accessor(user.CustomBool), "name", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.get(tt.key)
+			if got != tt.want || ok != tt.wantOK {
+				t.Fatalf("%s = %v (%T), %v, want %v (%T), %v", tt.key, got, got, ok, tt.want, tt.want, tt.wantOK)
+			}
+		})
+	}
+
+	if _, ok := (&User{}).CustomValue("name"); ok {
+		t.Fatal("CustomValue found a field on a user without custom data")
+	}
+}
+
+// accessor gives the typed accessors a common signature for the table
+func accessor[T any](get func(string) (T, bool)) func(string) (any, bool) {
+	return func(key string) (any, bool) {
+		value, ok := get(key)
+		return value, ok
+	}
+}
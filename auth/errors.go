@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrUserNotFound is returned when no user matches the lookup
+	ErrUserNotFound = errors.New("user not found")
+
+	// ErrEmailExists is returned by Signup when an account with the email exists
+	ErrEmailExists = errors.New("user with this email already exists")
+
+	// ErrLookupFailed wraps unexpected database errors during user lookups
+	ErrLookupFailed = errors.New("failed to look up user")
+
+	// ErrTokenRevoked is returned when a token was revoked explicitly or by version bump
+	ErrTokenRevoked = errors.New("token has been revoked")
+
+	// ErrPasswordMismatch is returned when ConfirmPassword differs from NewPassword
+	ErrPasswordMismatch = errors.New("password confirmation does not match")
+
+	// ErrPasswordReused is returned when the new password equals the current or a recent one
+	ErrPasswordReused = errors.New("new password must differ from recent passwords")
+
+	// ErrInvalidInvite is returned by Signup when RequireInvite is set and the
+	// invite is missing, unknown, used, expired or issued for another email
+	ErrInvalidInvite = errors.New("invalid or expired invite")
+
+	// ErrEmailDomainNotAllowed is returned by Signup when the email's domain is
+	// blocked or missing from AllowedEmailDomains
+	ErrEmailDomainNotAllowed = errors.New("email domain is not allowed")
+
+	// ErrInvalidCredentials is returned by Login for an unknown email or wrong password
+	ErrInvalidCredentials = errors.New("invalid credentials")
+
+	// ErrAccountLocked is returned by Login after too many failed attempts for the email
+	ErrAccountLocked = errors.New("too many failed login attempts, try again later")
+
+	// ErrInvalidToken is returned for unknown, used or expired reset/verification tokens
+	ErrInvalidToken = errors.New("invalid or expired token")
+)
+
+// FieldErrors maps field names to validation messages. CustomValidator
+// implementations can return it to report per-field problems.
+type FieldErrors map[string]string
+
+func (e FieldErrors) Error() string {
+	fields := make([]string, 0, len(e))
+	for field := range e {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	parts := make([]string, 0, len(fields))
+	for _, field := range fields {
+		parts = append(parts, field+": "+e[field])
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+// InvalidIDsError lists malformed IDs skipped by GetUsersByIDs
+type InvalidIDsError struct {
+	IDs []string
+}
+
+func (e *InvalidIDsError) Error() string {
+	return "invalid user IDs: " + strings.Join(e.IDs, ", ")
+}
@@ -1,42 +1,96 @@
 package auth
 
-import "github.com/gin-gonic/gin"
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net/http"
+    "strings"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/gin-gonic/gin/binding"
+    "go.mongodb.org/mongo-driver/bson"
+)
 
 // SignupHandler returns Gin handler for signup
 func (m *Manager) SignupHandler() gin.HandlerFunc {
     return func(c *gin.Context) {
+        if m.config.DisableSignup {
+            m.forbidden(c, "signup is disabled")
+            return
+        }
+
         var req SignupRequest
-        if err := c.BindJSON(&req); err != nil {
-            c.JSON(400, gin.H{"error": err.Error()})
+        if !m.bindBody(c, &req) {
             return
         }
+
+        if len(m.config.SignupCustomFields) > 0 {
+            var raw map[string]any
+            if !m.bindBody(c, &raw) {
+                return
+            }
+            m.captureCustomFields(&req, raw)
+        }
         
-        user, token, err := m.Signup(req)
+        user, token, err := m.SignupContext(c.Request.Context(), req)
+        if errors.Is(err, ErrLookupFailed) {
+            // The wrapped driver error is for the logs, not the client
+            m.config.OnError(fmt.Errorf("signup for %s: %w", req.Email, err))
+            RespondError(c, 500, CodeInternal, "internal error")
+            return
+        }
+        if errors.Is(err, ErrInvalidInvite) {
+            m.forbidden(c, err.Error())
+            return
+        }
+        if errors.Is(err, ErrEmailDomainNotAllowed) {
+            RespondValidationError(c, FieldErrors{"email": err.Error()})
+            return
+        }
+        var fields FieldErrors
+        if errors.As(err, &fields) {
+            RespondValidationError(c, fields)
+            return
+        }
         if err != nil {
-            c.JSON(400, gin.H{"error": err.Error()})
+            RespondError(c, 400, CodeBadRequest, err.Error())
             return
         }
         
-        c.JSON(201, AuthResponse{User: *user, Token: token})
+        RespondSuccess(c, 201, m.authResponse(user, m.deliverToken(c, token, false)))
     }
 }
 
 // LoginHandler returns Gin handler for login
 func (m *Manager) LoginHandler() gin.HandlerFunc {
     return func(c *gin.Context) {
+        ip := m.ClientIP(c)
+        if m.loginLimiter != nil && m.loginLimiter.blocked(ip) {
+            RespondError(c, 429, CodeTooManyRequests, "too many failed login attempts, try again later")
+            return
+        }
+
         var req LoginRequest
-        if err := c.BindJSON(&req); err != nil {
-            c.JSON(400, gin.H{"error": err.Error()})
+        if !m.bindBody(c, &req) {
             return
         }
 
-        user, token, err := m.Login(req)
+        user, token, err := m.LoginContext(c.Request.Context(), req)
+        if errors.Is(err, ErrAccountLocked) {
+            RespondError(c, 429, CodeTooManyRequests, err.Error())
+            return
+        }
         if err != nil {
-            c.JSON(401, gin.H{"error": "invalid credentials"})
+            if errors.Is(err, ErrInvalidCredentials) && m.loginLimiter != nil {
+                m.loginLimiter.allow(ip)
+            }
+            m.unauthorized(c, CodeInvalidCredentials, "invalid credentials")
             return
         }
 
-        c.JSON(200, AuthResponse{User: *user, Token: token})
+        RespondSuccess(c, 200, m.authResponse(user, m.deliverToken(c, token, req.RememberMe)))
     }
 }
 
@@ -44,87 +98,296 @@ func (m *Manager) LoginHandler() gin.HandlerFunc {
 func (m *Manager) GetProfileHandler() gin.HandlerFunc {
     return func(c *gin.Context) {
         // User ID comes from middleware
-        userID, exists := c.Get("userID")
-        if !exists {
-            c.JSON(401, gin.H{"error": "unauthorized"})
+        userID, ok := UserIDFromContext(c)
+        if !ok {
+            m.unauthorized(c, CodeUnauthorized, "unauthorized")
             return
         }
 
-        user, err := m.GetUserByID(userID.(string))
+        user, err := m.GetUserPublicContext(c.Request.Context(), userID)
         if err != nil {
-            c.JSON(404, gin.H{"error": "user not found"})
+            RespondError(c, 404, CodeNotFound, "user not found")
             return
         }
 
-        c.JSON(200, user)
+        RespondSuccess(c, 200, m.userResponse(user))
     }
 }
 
 // UpdateProfileHandler updates user profile
 func (m *Manager) UpdateProfileHandler() gin.HandlerFunc {
     return func(c *gin.Context) {
-        userID, exists := c.Get("userID")
-        if !exists {
-            c.JSON(401, gin.H{"error": "unauthorized"})
+        userID, ok := UserIDFromContext(c)
+        if !ok {
+            m.unauthorized(c, CodeUnauthorized, "unauthorized")
             return
         }
 
         var req UpdateProfileRequest
-        if err := c.BindJSON(&req); err != nil {
-            c.JSON(400, gin.H{"error": err.Error()})
+        if !m.bindBody(c, &req) {
             return
         }
 
-        user, err := m.UpdateProfile(userID.(string), req)
+        user, err := m.UpdateProfileContext(c.Request.Context(), userID, req)
+        var fields FieldErrors
+        if errors.As(err, &fields) {
+            RespondValidationError(c, fields)
+            return
+        }
         if err != nil {
-            c.JSON(400, gin.H{"error": err.Error()})
+            RespondError(c, 400, CodeBadRequest, err.Error())
             return
         }
 
-        c.JSON(200, user)
+        RespondSuccess(c, 200, m.userResponse(user.Public()))
     }
 }
 
 // ChangePasswordHandler changes user password
 func (m *Manager) ChangePasswordHandler() gin.HandlerFunc {
     return func(c *gin.Context) {
-        userID, exists := c.Get("userID")
-        if !exists {
-            c.JSON(401, gin.H{"error": "unauthorized"})
+        userID, ok := UserIDFromContext(c)
+        if !ok {
+            m.unauthorized(c, CodeUnauthorized, "unauthorized")
             return
         }
 
         var req ChangePasswordRequest
-        if err := c.BindJSON(&req); err != nil {
-            c.JSON(400, gin.H{"error": err.Error()})
+        if !m.bindBody(c, &req) {
+            return
+        }
+
+        user, err := m.changePassword(c.Request.Context(), userID, req)
+        if err != nil {
+            RespondError(c, 400, CodeBadRequest, err.Error())
             return
         }
+        m.auditRequest(c, AuditPasswordChanged, userID)
 
-        err := m.ChangePassword(userID.(string), req)
+        // The current token was invalidated along with all others, issue a
+        // fresh one at the bumped version
+        token, err := m.generateToken(userID, user.TokenVersion+1)
         if err != nil {
-            c.JSON(400, gin.H{"error": err.Error()})
+            RespondError(c, 500, CodeInternal, "failed to generate token")
             return
         }
 
-        c.JSON(200, gin.H{"message": "password changed successfully"})
+        body := gin.H{"message": "password changed successfully"}
+        if token = m.deliverToken(c, token, false); token != "" {
+            body["token"] = m.responseToken(token)
+        }
+        RespondSuccess(c, 200, body)
     }
 }
 
-// DeleteAccountHandler deletes user account
+// DeleteAccountHandler soft-deletes the caller's own account.
+// Admins can pass ?hard=true to delete their own account permanently; to
+// delete other users, call DeleteAccount from an admin-only route.
 func (m *Manager) DeleteAccountHandler() gin.HandlerFunc {
     return func(c *gin.Context) {
-        userID, exists := c.Get("userID")
-        if !exists {
-            c.JSON(401, gin.H{"error": "unauthorized"})
+        userID, ok := UserIDFromContext(c)
+        if !ok {
+            m.unauthorized(c, CodeUnauthorized, "unauthorized")
+            return
+        }
+
+        var err error
+        if c.Query("hard") == "true" {
+            user, lookupErr := m.contextUser(c, userID)
+            if lookupErr != nil {
+                RespondError(c, 404, CodeNotFound, "user not found")
+                return
+            }
+            if !m.IsAdmin(user) {
+                m.forbidden(c, "hard delete requires admin role")
+                return
+            }
+            err = m.DeleteAccountContext(c.Request.Context(), userID)
+        } else {
+            err = m.SoftDeleteAccountContext(c.Request.Context(), userID)
+        }
+        if err != nil {
+            RespondError(c, 400, CodeBadRequest, err.Error())
+            return
+        }
+        m.auditRequest(c, AuditAccountDeleted, userID)
+        m.ClearTokenCookie(c)
+
+        RespondSuccess(c, 200, gin.H{"message": "account deleted successfully"})
+    }
+}
+
+// ForgotPasswordHandler sends a password reset email.
+// It responds the same way whether or not the account exists, so the endpoint
+// can't be used to discover accounts. Requests are rate limited per email and
+// per IP, and each allowed one is recorded in the reset request audit collection.
+func (m *Manager) ForgotPasswordHandler() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        var req ForgotPasswordRequest
+        if !m.bindBody(c, &req) {
+            return
+        }
+
+        email := strings.ToLower(strings.TrimSpace(req.Email))
+        ip := m.ClientIP(c)
+
+        if m.resetLimiter != nil {
+            // Count both keys on every request, so neither can be bypassed
+            emailAllowed := m.resetLimiter.allow("email:" + email)
+            ipAllowed := m.resetLimiter.allow("ip:" + ip)
+            if !emailAllowed || !ipAllowed {
+                RespondError(c, 429, CodeTooManyRequests, "too many reset requests, try again later")
+                return
+            }
+        }
+
+        _, err := m.db.InsertOneContext(c.Request.Context(), m.config.Collections.ResetRequests, bson.M{
+            "email":        email,
+            "ip":           ip,
+            "requested_at": time.Now(),
+        })
+        if err != nil {
+            m.config.OnError(fmt.Errorf("failed to record reset request for %s: %w", email, err))
+        }
+
+        // Send in the background so the response time doesn't reveal whether
+        // the account exists. The request context ends with the response, so
+        // the background work gets a deadline of its own.
+        ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), 30*time.Second)
+        go func() {
+            defer cancel()
+            if _, err := m.RequestPasswordResetContext(ctx, req.Email); err != nil && !errors.Is(err, ErrUserNotFound) {
+                m.config.OnError(fmt.Errorf("failed to send password reset to %s: %w", email, err))
+            }
+        }()
+
+        RespondSuccess(c, 200, gin.H{"message": "if the account exists, a reset link has been sent"})
+    }
+}
+
+// ResetPasswordHandler sets a new password using a reset token
+func (m *Manager) ResetPasswordHandler() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        var req ResetPasswordRequest
+        if !m.bindBody(c, &req) {
             return
         }
 
-        err := m.DeleteAccount(userID.(string))
+        userID, err := m.resetPassword(req.Token, req.NewPassword)
         if err != nil {
-            c.JSON(400, gin.H{"error": err.Error()})
+            RespondError(c, 400, CodeBadRequest, err.Error())
+            return
+        }
+        m.auditRequest(c, AuditPasswordReset, userID)
+
+        RespondSuccess(c, 200, gin.H{"message": "password reset successfully"})
+    }
+}
+
+// RequestVerificationHandler sends a verification email to the current user
+func (m *Manager) RequestVerificationHandler() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        userID, ok := UserIDFromContext(c)
+        if !ok {
+            m.unauthorized(c, CodeUnauthorized, "unauthorized")
+            return
+        }
+
+        if _, err := m.RequestEmailVerification(userID); err != nil {
+            RespondError(c, 400, CodeBadRequest, err.Error())
+            return
+        }
+
+        RespondSuccess(c, 200, gin.H{"message": "verification email sent"})
+    }
+}
+
+// VerifyEmailHandler verifies the email address using a verification token
+func (m *Manager) VerifyEmailHandler() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        var req VerifyEmailRequest
+        if !m.bindBody(c, &req) {
+            return
+        }
+
+        if err := m.VerifyEmail(req.Token); err != nil {
+            RespondError(c, 400, CodeBadRequest, err.Error())
             return
         }
 
-        c.JSON(200, gin.H{"message": "account deleted successfully"})
+        RespondSuccess(c, 200, gin.H{"message": "email verified successfully"})
     }
-}
\ No newline at end of file
+}
+
+// captureCustomFields copies allowlisted top-level signup fields into Custom.
+// Values sent explicitly inside "custom" take precedence.
+func (m *Manager) captureCustomFields(req *SignupRequest, raw map[string]any) {
+    for _, key := range m.config.SignupCustomFields {
+        if reservedSignupFields[strings.ToLower(key)] {
+            continue
+        }
+
+        value, ok := raw[key]
+        if !ok {
+            continue
+        }
+        if req.Custom == nil {
+            req.Custom = make(map[string]interface{})
+        }
+        if _, exists := req.Custom[key]; !exists {
+            req.Custom[key] = value
+        }
+    }
+}
+
+// bindBody binds a JSON body, or a form body when AllowFormBodies is set,
+// within the configured size limit and writes a 415, 413 or 400 response on failure
+func (m *Manager) bindBody(c *gin.Context, obj any) bool {
+    c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, m.config.MaxBodyBytes)
+
+    var err error
+    switch contentType := c.ContentType(); {
+    case contentType == "" || contentType == binding.MIMEJSON:
+        // The body is cached, so handlers can bind it more than once
+        err = c.ShouldBindBodyWithJSON(obj)
+    case m.config.AllowFormBodies && (contentType == binding.MIMEPOSTForm || contentType == binding.MIMEMultipartPOSTForm):
+        err = bindForm(c, obj)
+    default:
+        RespondError(c, 415, CodeUnsupportedMediaType, "unsupported content type "+contentType)
+        return false
+    }
+
+    if err != nil {
+        var maxErr *http.MaxBytesError
+        if errors.As(err, &maxErr) {
+            RespondError(c, 413, CodePayloadTooLarge, "request body too large")
+            return false
+        }
+        RespondError(c, 400, CodeBadRequest, err.Error())
+        return false
+    }
+    return true
+}
+
+// bindForm binds a urlencoded or multipart form; a *map[string]any receives
+// the first value of every field
+func bindForm(c *gin.Context, obj any) error {
+    raw, ok := obj.(*map[string]any)
+    if !ok {
+        return c.ShouldBind(obj)
+    }
+
+    if c.ContentType() == binding.MIMEMultipartPOSTForm {
+        if _, err := c.MultipartForm(); err != nil {
+            return err
+        }
+    } else if err := c.Request.ParseForm(); err != nil {
+        return err
+    }
+
+    *raw = make(map[string]any, len(c.Request.PostForm))
+    for key, values := range c.Request.PostForm {
+        (*raw)[key] = values[0]
+    }
+    return nil
+}
@@ -0,0 +1,531 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// failingMailer reports every email it was asked to send as failed
+type failingMailer struct{}
+
+func (failingMailer) Send(to, subject, body string) error {
+	return errors.New("smtp unavailable")
+}
+
+func TestForgotPasswordHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	errs := make(chan error, 10)
+	m := newTestManager(t, &Config{
+		ResetRateLimit: 2,
+		Mailer:         failingMailer{},
+		OnError:        func(err error) { errs <- err },
+	})
+	mustSignup(t, m, "known@example.com", "correct horse battery")
+
+	router := gin.New()
+	router.POST("/forgot", m.ForgotPasswordHandler())
+	forgot := func(email, ip string) (int, string) {
+		req := httptest.NewRequest("POST", "/forgot", strings.NewReader(`{"email":"`+email+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code, w.Body.String()
+	}
+
+	tests := []struct {
+		name       string
+		email      string
+		ip         string
+		wantStatus int
+		wantSend   bool // a send was attempted, so the mailer error reaches OnError
+	}{
+		{"known email", "known@example.com", "10.0.0.1", 200, true},
+		{"unknown email", "unknown@example.com", "10.0.0.1", 200, false},
+		{"ip over the limit", "other@example.com", "10.0.0.1", 429, false},
+		{"second request for known email", "known@example.com", "10.0.0.2", 200, true},
+		{"email over the limit", "known@example.com", "10.0.0.3", 429, false},
+	}
+
+	var bodies []string
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := forgot(tt.email, tt.ip)
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if status == 200 {
+				bodies = append(bodies, body)
+			}
+
+			select {
+			case err := <-errs:
+				if !tt.wantSend {
+					t.Fatalf("unexpected OnError: %v", err)
+				}
+			case <-time.After(time.Second):
+				if tt.wantSend {
+					t.Fatal("mailer failure not reported to OnError")
+				}
+			}
+		})
+	}
+
+	for _, body := range bodies[1:] {
+		if body != bodies[0] {
+			t.Fatalf("responses differ: %q and %q", bodies[0], body)
+		}
+	}
+
+	recorded, err := m.db.Collection(m.config.Collections.ResetRequests).CountDocuments(t.Context(), bson.M{})
+	if err != nil {
+		t.Fatalf("count reset requests: %v", err)
+	}
+	if recorded != 3 {
+		t.Fatalf("%d reset requests recorded, want the 3 allowed ones", recorded)
+	}
+}
+
+// recordingAuditLogger keeps events in memory and fails when err is set
+type recordingAuditLogger struct {
+	events []AuditEvent
+	err    error
+}
+
+func (l *recordingAuditLogger) Log(event AuditEvent) error {
+	l.events = append(l.events, event)
+	return l.err
+}
+
+func TestChangePasswordHandlerAudits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name      string
+		loggerErr error
+	}{
+		{"event recorded", nil},
+		{"logger failure reported", errors.New("audit store down")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := &recordingAuditLogger{err: tt.loggerErr}
+			var reported []error
+			m := newTestManager(t, &Config{
+				AuditLogger: logger,
+				OnError:     func(err error) { reported = append(reported, err) },
+			})
+			user := mustSignup(t, m, "audit@example.com", "password-0")
+			_, token, err := m.Login(LoginRequest{Email: user.Email, Password: "password-0"})
+			if err != nil {
+				t.Fatalf("Login: %v", err)
+			}
+
+			router := gin.New()
+			router.POST("/change-password", m.Middleware(), m.ChangePasswordHandler())
+			req := httptest.NewRequest("POST", "/change-password", strings.NewReader(`{"old_password":"password-0","new_password":"password-1"}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+token)
+			req.RemoteAddr = "203.0.113.7:1234"
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != 200 {
+				t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+			}
+			var resp struct {
+				Token string `json:"token"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode %s: %v", w.Body.String(), err)
+			}
+			if _, err := m.ValidateToken(resp.Token); err != nil {
+				t.Fatalf("ValidateToken of the new token: %v", err)
+			}
+			if _, err := m.ValidateToken(token); !errors.Is(err, ErrTokenRevoked) {
+				t.Fatalf("ValidateToken of the old token = %v, want ErrTokenRevoked", err)
+			}
+			if len(logger.events) != 1 {
+				t.Fatalf("%d audit events, want 1", len(logger.events))
+			}
+			event := logger.events[0]
+			if event.Type != AuditPasswordChanged || event.UserID != user.ID || event.IP != "203.0.113.7" || event.Timestamp.IsZero() {
+				t.Fatalf("audit event = %+v", event)
+			}
+			if wantReported := tt.loggerErr != nil; (len(reported) > 0) != wantReported {
+				t.Fatalf("OnError calls = %v, want reported %v", reported, wantReported)
+			}
+		})
+	}
+}
+
+func TestMongoAuditLogger(t *testing.T) {
+	m := newTestManager(t, &Config{})
+	user := mustSignup(t, m, "audit@example.com", "password-0")
+
+	tests := []struct {
+		name      string
+		eventType string
+	}{
+		{"password changed", AuditPasswordChanged},
+		{"email changed", AuditEmailChanged},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := m.Audit(tt.eventType, user.ID, "203.0.113.7"); err != nil {
+				t.Fatalf("Audit: %v", err)
+			}
+
+			var event AuditEvent
+			filter := bson.M{"type": tt.eventType, "user_id": user.ID}
+			if err := m.db.FindOne(m.config.Collections.AuditLog, filter, &event); err != nil {
+				t.Fatalf("load event: %v", err)
+			}
+			if event.IP != "203.0.113.7" || event.Timestamp.IsZero() {
+				t.Fatalf("stored event = %+v", event)
+			}
+		})
+	}
+}
+
+// bindRouter serves a route that binds the body with bindBody and echoes it
+func bindRouter(m *Manager) *gin.Engine {
+	router := gin.New()
+	router.POST("/bind", func(c *gin.Context) {
+		var req LoginRequest
+		if !m.bindBody(c, &req) {
+			return
+		}
+		c.String(200, req.Email)
+	})
+	return router
+}
+
+func TestDeleteAccountHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := newTestManager(t, &Config{})
+
+	tests := []struct {
+		name       string
+		role       string
+		query      string
+		wantStatus int
+		wantState  string // "active", "soft deleted" or "hard deleted"
+	}{
+		{"soft delete by default", "", "", 200, "soft deleted"},
+		{"hard delete by an admin", "admin", "?hard=true", 200, "hard deleted"},
+		{"hard delete by a member", "", "?hard=true", 403, "active"},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := mustSignup(t, m, fmt.Sprintf("delete-%d@example.com", i), "correct horse battery")
+			id := mustObjectID(t, user.ID)
+			if tt.role != "" {
+				if err := m.db.UpdateOne(m.config.DatabaseName, bson.M{"_id": id}, bson.M{"$set": bson.M{"role": tt.role}}); err != nil {
+					t.Fatalf("set role: %v", err)
+				}
+			}
+
+			router := gin.New()
+			router.DELETE("/account", func(c *gin.Context) { c.Set("userID", user.ID) }, m.DeleteAccountHandler())
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("DELETE", "/account"+tt.query, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+
+			var doc bson.M
+			err := m.db.Collection(m.config.DatabaseName).FindOne(t.Context(), bson.M{"_id": id}).Decode(&doc)
+			state := "active"
+			switch {
+			case errors.Is(err, mongo.ErrNoDocuments):
+				state = "hard deleted"
+			case err != nil:
+				t.Fatalf("find: %v", err)
+			case doc["deleted_at"] != nil:
+				state = "soft deleted"
+			}
+			if state != tt.wantState {
+				t.Fatalf("account is %s, want %s", state, tt.wantState)
+			}
+		})
+	}
+}
+
+func TestBindBodySizeLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		limit      int64
+		padding    int
+		wantStatus int
+	}{
+		{"default limit, small body", 0, 100, 200},
+		{"default limit, large body", 0, 2 << 20, 413},
+		{"custom limit, under", 256, 100, 200},
+		{"custom limit, over", 256, 300, 413},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newOfflineManager(t, &Config{MaxBodyBytes: tt.limit})
+			body := `{"email":"a@example.com","password":"` + strings.Repeat("x", tt.padding) + `"}`
+			req := httptest.NewRequest("POST", "/bind", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			bindRouter(m).ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestCaptureCustomFields(t *testing.T) {
+	m := newOfflineManager(t, &Config{SignupCustomFields: []string{"name", "company"}})
+
+	tests := []struct {
+		name   string
+		custom map[string]any
+		raw    map[string]any
+		want   map[string]any
+	}{
+		{"captures allowlisted fields", nil, map[string]any{"name": "Ada", "company": "ACME"}, map[string]any{"name": "Ada", "company": "ACME"}},
+		{"ignores other fields", nil, map[string]any{"name": "Ada", "plan": "pro"}, map[string]any{"name": "Ada"}},
+		{"nothing to capture", nil, map[string]any{"plan": "pro"}, nil},
+		{"explicit custom wins", map[string]any{"name": "Grace"}, map[string]any{"name": "Ada"}, map[string]any{"name": "Grace"}},
+		{"merges with custom", map[string]any{"plan": "pro"}, map[string]any{"company": "ACME"}, map[string]any{"plan": "pro", "company": "ACME"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := SignupRequest{Custom: tt.custom}
+			m.captureCustomFields(&req, tt.raw)
+			if !reflect.DeepEqual(req.Custom, tt.want) {
+				t.Fatalf("Custom = %v, want %v", req.Custom, tt.want)
+			}
+		})
+	}
+}
+
+func TestDisableSignup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		disabled   bool
+		body       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"disabled", true, `{"email":"a@example.com","password":"correct horse battery"}`, 403, CodeForbidden},
+		{"disabled, bad body", true, `{`, 403, CodeForbidden},
+		// Enabled signup gets as far as validating the body
+		{"enabled, bad body", false, `{`, 400, CodeBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newOfflineManager(t, &Config{DisableSignup: tt.disabled})
+			router := gin.New()
+			router.POST("/signup", m.SignupHandler())
+
+			req := httptest.NewRequest("POST", "/signup", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			var resp ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode %s: %v", w.Body.String(), err)
+			}
+			if w.Code != tt.wantStatus || resp.Error.Code != tt.wantCode {
+				t.Fatalf("response = %d %s, want %d %s", w.Code, resp.Error.Code, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestSignupHandlerLookupFailure(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var reported []error
+	m := newTestManager(t, &Config{OnError: func(err error) { reported = append(reported, err) }})
+	router := gin.New()
+	router.POST("/signup", m.SignupHandler())
+
+	// A cancelled request makes the duplicate-email lookup fail
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest("POST", "/signup", strings.NewReader(`{"email":"a@example.com","password":"correct horse battery"}`)).WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var resp ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode %s: %v", w.Body.String(), err)
+	}
+	if w.Code != 500 || resp.Error.Code != CodeInternal || resp.Error.Message != "internal error" {
+		t.Fatalf("response = %d %s %q, want 500 %s \"internal error\"", w.Code, resp.Error.Code, resp.Error.Message, CodeInternal)
+	}
+	if len(reported) != 1 || !errors.Is(reported[0], ErrLookupFailed) {
+		t.Fatalf("OnError calls = %v, want one wrapping ErrLookupFailed", reported)
+	}
+}
+
+func TestLoginTokenDelivery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		delivery   string
+		wantCookie bool
+		wantBody   bool
+	}{
+		{"body", DeliverBody, false, true},
+		{"cookie", DeliverCookie, true, false},
+		{"both", DeliverBoth, true, true},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t, &Config{TokenDelivery: tt.delivery})
+			user := mustSignup(t, m, fmt.Sprintf("delivery-%d@example.com", i), "correct horse battery")
+			router := gin.New()
+			router.POST("/login", m.LoginHandler())
+
+			body := fmt.Sprintf(`{"email":%q,"password":"correct horse battery"}`, user.Email)
+			req := httptest.NewRequest("POST", "/login", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != 200 {
+				t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+			}
+
+			var resp struct {
+				Token string `json:"token"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode %s: %v", w.Body.String(), err)
+			}
+			if (resp.Token != "") != tt.wantBody {
+				t.Fatalf("body token = %q, want one: %v", resp.Token, tt.wantBody)
+			}
+
+			var cookie string
+			for _, c := range w.Result().Cookies() {
+				if c.Name == m.config.Cookie.Name {
+					if !c.HttpOnly {
+						t.Fatal("token cookie isn't HttpOnly")
+					}
+					cookie = c.Value
+				}
+			}
+			if (cookie != "") != tt.wantCookie {
+				t.Fatalf("cookie = %q, want one: %v", cookie, tt.wantCookie)
+			}
+			if tt.wantCookie && tt.wantBody && cookie != resp.Token {
+				t.Fatal("cookie and body carry different tokens")
+			}
+		})
+	}
+}
+
+func TestBindBodyContentType(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const (
+		jsonBody = `{"email":"a@example.com","password":"pw"}`
+		formBody = "email=a%40example.com&password=pw"
+	)
+
+	tests := []struct {
+		name        string
+		allowForms  bool
+		contentType string
+		body        string
+		wantStatus  int
+	}{
+		{"json", false, "application/json", jsonBody, 200},
+		{"json with charset", false, "application/json; charset=utf-8", jsonBody, 200},
+		{"no content type", false, "", jsonBody, 200},
+		{"form, not allowed", false, "application/x-www-form-urlencoded", formBody, 415},
+		{"form, allowed", true, "application/x-www-form-urlencoded", formBody, 200},
+		{"xml", true, "application/xml", "<email>a@example.com</email>", 415},
+		{"text", false, "text/plain", jsonBody, 415},
+		{"malformed json", false, "application/json", "{", 400},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newOfflineManager(t, &Config{AllowFormBodies: tt.allowForms})
+			req := httptest.NewRequest("POST", "/bind", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			bindRouter(m).ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus == 200 && w.Body.String() != "a@example.com" {
+				t.Fatalf("bound email = %q, want a@example.com", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestBindFormMultipart(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := newOfflineManager(t, &Config{AllowFormBodies: true})
+
+	tests := []struct {
+		name   string
+		fields map[string]string
+		want   string
+	}{
+		{"email", map[string]string{"email": "multi@example.com", "password": "pw"}, "multi@example.com"},
+		{"empty", map[string]string{}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body bytes.Buffer
+			form := multipart.NewWriter(&body)
+			for key, value := range tt.fields {
+				form.WriteField(key, value)
+			}
+			form.Close()
+
+			req := httptest.NewRequest("POST", "/bind", &body)
+			req.Header.Set("Content-Type", form.FormDataContentType())
+			w := httptest.NewRecorder()
+			bindRouter(m).ServeHTTP(w, req)
+
+			if w.Code != 200 || w.Body.String() != tt.want {
+				t.Fatalf("response = %d %q, want 200 %q", w.Code, w.Body.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/berkkaradalan/CoreGo/database"
+)
+
+// testMongoURL points the database-backed tests at a MongoDB server;
+// they are skipped when it is unset
+const testMongoURL = "COREGO_TEST_MONGODB_URL"
+
+// newTestDB connects to a fresh database that is dropped after the test
+func newTestDB(t *testing.T) *database.MongoDB {
+	t.Helper()
+
+	url := os.Getenv(testMongoURL)
+	if url == "" {
+		t.Skipf("%s is not set", testMongoURL)
+	}
+
+	db, err := database.NewMongoDB(&database.MongoConfig{
+		URL:      url,
+		Database: fmt.Sprintf("corego_auth_test_%d", time.Now().UnixNano()),
+	})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Database().Drop(context.Background())
+		db.Disconnect()
+	})
+	return db
+}
+
+// testPostgresURL points the Postgres-backed tests at a server; they are
+// skipped when it is unset
+const testPostgresURL = "COREGO_TEST_POSTGRES_URL"
+
+// newTestPostgres connects to the Postgres test server
+func newTestPostgres(t *testing.T) *database.PostgresDB {
+	t.Helper()
+
+	url := os.Getenv(testPostgresURL)
+	if url == "" {
+		t.Skipf("%s is not set", testPostgresURL)
+	}
+
+	db, err := database.NewPostgresDB(&database.PostgresConfig{URL: url})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { db.Disconnect() })
+	return db
+}
+
+// newTestTable returns a fresh table name that is dropped after the test
+func newTestTable(t *testing.T, db *database.PostgresDB) string {
+	t.Helper()
+
+	table := fmt.Sprintf("corego_users_%d", time.Now().UnixNano())
+	t.Cleanup(func() { db.Exec(`DROP TABLE IF EXISTS ` + table) })
+	return table
+}
+
+// newTestManager returns a Manager with config on a fresh database
+func newTestManager(t *testing.T, config *Config) *Manager {
+	t.Helper()
+
+	if config.Secret == "" {
+		config.Secret = "test-secret"
+	}
+	m, err := New(config, newTestDB(t))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return m
+}
+
+// newOfflineManager returns a Manager for tests that never reach the database
+func newOfflineManager(t *testing.T, config *Config) *Manager {
+	t.Helper()
+
+	if config.Secret == "" {
+		config.Secret = "test-secret"
+	}
+	m, err := New(config, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return m
+}
+
+// mustSignup creates a user and fails the test on error
+func mustSignup(t *testing.T, m *Manager, email, password string) *User {
+	t.Helper()
+
+	user, _, err := m.Signup(SignupRequest{Email: email, Password: password})
+	if err != nil {
+		t.Fatalf("Signup(%s): %v", email, err)
+	}
+	return user
+}
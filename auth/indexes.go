@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EnsureIndexes creates the indexes declared in config, plus TTL indexes
+// so expired auth artifacts are removed automatically. It is idempotent.
+func (m *Manager) EnsureIndexes() error {
+	for _, collection := range []string{
+		m.config.Collections.RevokedTokens,
+		m.config.Collections.PasswordResets,
+		m.config.Collections.EmailVerifications,
+		m.config.Collections.Invites,
+	} {
+		if err := m.db.EnsureTTLIndex(collection, "expires_at", 0); err != nil {
+			return fmt.Errorf("failed to create TTL index on %s: %w", collection, err)
+		}
+	}
+
+	if model, ok := m.emailIndex(); ok {
+		if err := m.db.EnsureIndex(m.config.DatabaseName, model); err != nil {
+			return fmt.Errorf("failed to create unique email index: %w", err)
+		}
+	}
+
+	for _, spec := range m.config.Indexes {
+		model, err := spec.model()
+		if err != nil {
+			return err
+		}
+		if err := m.db.EnsureIndex(m.config.DatabaseName, model); err != nil {
+			return fmt.Errorf("failed to create index on %s: %w", strings.Join(spec.Fields, ","), err)
+		}
+	}
+	return nil
+}
+
+// emailCollation compares strings ignoring case but not accents. Queries must
+// use the same collation to match case-insensitively and to use the index.
+var emailCollation = &options.Collation{Locale: "en", Strength: 2}
+
+// Index names for Config.EmailIndex; distinct so switching modes doesn't
+// conflict with the index created by the other one
+const (
+	emailIndexName                = "email_unique"
+	emailIndexCaseInsensitiveName = "email_unique_ci"
+)
+
+// emailIndex returns the unique email index configured by EmailIndex, if any
+func (m *Manager) emailIndex() (mongo.IndexModel, bool) {
+	opts := options.Index().SetUnique(true)
+	switch m.config.EmailIndex {
+	case EmailIndexUnique:
+		opts.SetName(emailIndexName)
+	case EmailIndexCaseInsensitive:
+		opts.SetName(emailIndexCaseInsensitiveName).SetCollation(emailCollation)
+	default:
+		return mongo.IndexModel{}, false
+	}
+	return mongo.IndexModel{Keys: bson.D{{Key: "email", Value: 1}}, Options: opts}, true
+}
+
+// isDuplicateEmail reports whether err is a violation of the unique email index,
+// identified by the key pattern the server reports for the violated index
+func isDuplicateEmail(err error) bool {
+	var writeErr mongo.WriteException
+	if !mongo.IsDuplicateKeyError(err) || !errors.As(err, &writeErr) {
+		return false
+	}
+	for _, we := range writeErr.WriteErrors {
+		var dup struct {
+			KeyPattern bson.D `bson:"keyPattern"`
+		}
+		if err := bson.Unmarshal(we.Raw, &dup); err != nil {
+			continue
+		}
+		if len(dup.KeyPattern) == 1 && dup.KeyPattern[0].Key == "email" {
+			return true
+		}
+	}
+	return false
+}
+
+// model converts the spec into a driver index model
+func (s IndexSpec) model() (mongo.IndexModel, error) {
+	if len(s.Fields) == 0 {
+		return mongo.IndexModel{}, errors.New("index requires at least one field")
+	}
+
+	keys := bson.D{}
+	for _, field := range s.Fields {
+		order := 1
+		if strings.HasPrefix(field, "-") {
+			order = -1
+			field = strings.TrimPrefix(field, "-")
+		}
+		if field == "" {
+			return mongo.IndexModel{}, errors.New("index field name is empty")
+		}
+		keys = append(keys, bson.E{Key: field, Value: order})
+	}
+
+	opts := options.Index()
+	if s.Unique {
+		opts.SetUnique(true)
+	}
+	if s.Name != "" {
+		opts.SetName(s.Name)
+	}
+
+	return mongo.IndexModel{Keys: keys, Options: opts}, nil
+}
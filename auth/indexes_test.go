@@ -0,0 +1,335 @@
+package auth
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestIsDuplicateEmail(t *testing.T) {
+	writeErr := func(code int, keyPattern bson.D) error {
+		raw, err := bson.Marshal(bson.D{{Key: "code", Value: code}, {Key: "keyPattern", Value: keyPattern}})
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		return mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: code, Message: "E11000 duplicate key error", Raw: raw}}}
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"other error", errors.New("email_unique"), false},
+		{"email index", writeErr(11000, bson.D{{Key: "email", Value: 1}}), true},
+		{"other unique index", writeErr(11000, bson.D{{Key: "custom.code", Value: 1}}), false},
+		{"compound index with email", writeErr(11000, bson.D{{Key: "email", Value: 1}, {Key: "tenant", Value: 1}}), false},
+		{"not a duplicate", writeErr(121, bson.D{{Key: "email", Value: 1}}), false},
+		{"no key pattern", mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 11000, Message: "E11000 duplicate key error index: email_unique"}}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isDuplicateEmail(tt.err); got != tt.want {
+				t.Fatalf("isDuplicateEmail(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignupEmailIndex(t *testing.T) {
+	const exists = "user with this email already exists"
+
+	tests := []struct {
+		name    string
+		index   string
+		first   string
+		second  string
+		wantErr string
+	}{
+		{"unique, same email", EmailIndexUnique, "same@example.com", "same@example.com", exists},
+		{"unique, other case", EmailIndexUnique, "case@example.com", "Case@Example.com", ""},
+		{"case insensitive, same email", EmailIndexCaseInsensitive, "same@example.com", "same@example.com", exists},
+		{"case insensitive, other case", EmailIndexCaseInsensitive, "case@example.com", "Case@Example.com", exists},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t, &Config{EmailIndex: tt.index})
+			mustSignup(t, m, tt.first, "correct horse battery")
+
+			_, _, err := m.Signup(SignupRequest{Email: tt.second, Password: "correct horse battery"})
+			if got := errString(err); got != tt.wantErr {
+				t.Fatalf("second Signup error = %q, want %q", got, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSignupAfterSoftDelete(t *testing.T) {
+	for _, index := range []string{EmailIndexUnique, EmailIndexCaseInsensitive} {
+		t.Run(index, func(t *testing.T) {
+			m := newTestManager(t, &Config{EmailIndex: index})
+			deleted := mustSignup(t, m, "again@example.com", "correct horse battery")
+			if err := m.SoftDeleteAccount(deleted.ID); err != nil {
+				t.Fatalf("SoftDeleteAccount: %v", err)
+			}
+			// A second soft delete must keep the original email
+			if err := m.SoftDeleteAccount(deleted.ID); err != nil {
+				t.Fatalf("second SoftDeleteAccount: %v", err)
+			}
+
+			user, _, err := m.Signup(SignupRequest{Email: "again@example.com", Password: "battery staple horse"})
+			if err != nil {
+				t.Fatalf("Signup after soft delete: %v", err)
+			}
+			if user.ID == deleted.ID {
+				t.Fatal("Signup returned the soft-deleted account")
+			}
+			if _, _, err := m.Login(LoginRequest{Email: "again@example.com", Password: "battery staple horse"}); err != nil {
+				t.Fatalf("Login: %v", err)
+			}
+
+			var doc bson.M
+			if err := m.db.FindOne(m.config.DatabaseName, bson.M{"_id": mustObjectID(t, deleted.ID)}, &doc); err != nil {
+				t.Fatalf("load deleted user: %v", err)
+			}
+			if doc["deleted_email"] != "again@example.com" || doc["email"] != "deleted:"+deleted.ID {
+				t.Fatalf("deleted user email, deleted_email = %v, %v", doc["email"], doc["deleted_email"])
+			}
+		})
+	}
+}
+
+func TestSignupEmailIndexCollision(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *Config
+		emails  []string
+		wantErr string
+	}{
+		{"concurrent signups, same email", &Config{EmailIndex: EmailIndexUnique}, []string{"race@example.com", "race@example.com"}, "user with this email already exists"},
+		{"concurrent signups, other case", &Config{EmailIndex: EmailIndexCaseInsensitive}, []string{"race@example.com", "RACE@example.com"}, "user with this email already exists"},
+		// A violation of another unique index isn't reported as a taken email
+		{"other unique index", &Config{
+			EmailIndex: EmailIndexUnique,
+			Indexes:    []IndexSpec{{Fields: []string{"custom.code"}, Unique: true}},
+		}, []string{"one@example.com", "two@example.com"}, "failed to create user"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t, tt.config)
+
+			var wg sync.WaitGroup
+			errs := make(chan error, len(tt.emails))
+			for _, email := range tt.emails {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, _, err := m.Signup(SignupRequest{Email: email, Password: "correct horse battery", Custom: map[string]any{"code": "same"}})
+					errs <- err
+				}()
+			}
+			wg.Wait()
+			close(errs)
+
+			var failed []string
+			for err := range errs {
+				if err != nil {
+					failed = append(failed, err.Error())
+				}
+			}
+			if len(failed) != len(tt.emails)-1 {
+				t.Fatalf("%d signups failed (%v), want %d", len(failed), failed, len(tt.emails)-1)
+			}
+			for _, msg := range failed {
+				if msg != tt.wantErr {
+					t.Fatalf("Signup error = %q, want %q", msg, tt.wantErr)
+				}
+			}
+		})
+	}
+}
+
+func TestFindByEmailCaseInsensitive(t *testing.T) {
+	m := newTestManager(t, &Config{EmailIndex: EmailIndexCaseInsensitive})
+	mustSignup(t, m, "Found@example.com", "correct horse battery")
+	deleted := mustSignup(t, m, "deleted@example.com", "correct horse battery")
+	if err := m.SoftDeleteAccount(deleted.ID); err != nil {
+		t.Fatalf("SoftDeleteAccount: %v", err)
+	}
+
+	tests := []struct {
+		email string
+		want  int
+	}{
+		{"Found@example.com", 1},
+		{"found@EXAMPLE.com", 1},
+		{"DELETED@example.com", 0},
+		{"missing@example.com", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			users, err := m.findByEmail(t.Context(), tt.email)
+			if err != nil {
+				t.Fatalf("findByEmail: %v", err)
+			}
+			if len(users) != tt.want {
+				t.Fatalf("%d users found, want %d", len(users), tt.want)
+			}
+		})
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func TestIndexSpecModel(t *testing.T) {
+	tests := []struct {
+		name       string
+		spec       IndexSpec
+		wantKeys   bson.D
+		wantUnique bool
+		wantName   string
+		wantErr    bool
+	}{
+		{"single field", IndexSpec{Fields: []string{"custom.plan"}}, bson.D{{Key: "custom.plan", Value: 1}}, false, "", false},
+		{"descending and unique", IndexSpec{Fields: []string{"custom.tenant", "-created_at"}, Unique: true}, bson.D{{Key: "custom.tenant", Value: 1}, {Key: "created_at", Value: -1}}, true, "", false},
+		{"named", IndexSpec{Fields: []string{"custom.code"}, Name: "code_idx"}, bson.D{{Key: "custom.code", Value: 1}}, false, "code_idx", false},
+		{"no fields", IndexSpec{}, nil, false, "", true},
+		{"empty field", IndexSpec{Fields: []string{"-"}}, nil, false, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model, err := tt.spec.model()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("model error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(model.Keys, tt.wantKeys) {
+				t.Fatalf("keys = %v, want %v", model.Keys, tt.wantKeys)
+			}
+			unique := model.Options.Unique != nil && *model.Options.Unique
+			name := ""
+			if model.Options.Name != nil {
+				name = *model.Options.Name
+			}
+			if unique != tt.wantUnique || name != tt.wantName {
+				t.Fatalf("unique, name = %v, %q, want %v, %q", unique, name, tt.wantUnique, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestEnsureIndexesCustomFields(t *testing.T) {
+	m := newTestManager(t, &Config{Indexes: []IndexSpec{
+		{Fields: []string{"custom.plan"}},
+		{Fields: []string{"custom.code"}, Unique: true, Name: "code_idx"},
+	}})
+
+	specs, err := m.db.Collection(m.config.DatabaseName).Indexes().ListSpecifications(t.Context())
+	if err != nil {
+		t.Fatalf("list indexes: %v", err)
+	}
+	indexes := make(map[string]*mongo.IndexSpecification)
+	for _, spec := range specs {
+		indexes[spec.Name] = spec
+	}
+
+	tests := []struct {
+		name       string
+		wantUnique bool
+	}{
+		{"custom.plan_1", false},
+		{"code_idx", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, ok := indexes[tt.name]
+			if !ok {
+				t.Fatalf("index %s missing, have %v", tt.name, specs)
+			}
+			if unique := spec.Unique != nil && *spec.Unique; unique != tt.wantUnique {
+				t.Fatalf("unique = %v, want %v", unique, tt.wantUnique)
+			}
+		})
+	}
+}
+
+func TestEnsureIndexesTTL(t *testing.T) {
+	m := newTestManager(t, &Config{Collections: Collections{Invites: "app_invites"}})
+
+	tests := []string{
+		m.config.Collections.RevokedTokens,
+		m.config.Collections.PasswordResets,
+		m.config.Collections.EmailVerifications,
+		"app_invites",
+	}
+
+	for _, collection := range tests {
+		t.Run(collection, func(t *testing.T) {
+			specs, err := m.db.Collection(collection).Indexes().ListSpecifications(t.Context())
+			if err != nil {
+				t.Fatalf("list indexes: %v", err)
+			}
+			for _, spec := range specs {
+				if spec.Name == "expires_at_1" && spec.ExpireAfterSeconds != nil && *spec.ExpireAfterSeconds == 0 {
+					return
+				}
+			}
+			t.Fatalf("no TTL index on expires_at, have %v", specs)
+		})
+	}
+}
+
+func TestEnsureIndexesToggle(t *testing.T) {
+	tests := []struct {
+		name   string
+		ensure *bool
+		want   bool
+	}{
+		{"default", nil, true},
+		{"enabled", Bool(true), true},
+		{"disabled", Bool(false), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t, &Config{EmailIndex: EmailIndexUnique, EnsureIndexes: tt.ensure})
+
+			specs, err := m.db.Collection(m.config.DatabaseName).Indexes().ListSpecifications(t.Context())
+			if err != nil && tt.want {
+				t.Fatalf("list indexes: %v", err)
+			}
+			found := false
+			for _, spec := range specs {
+				found = found || spec.Name == emailIndexName
+			}
+			if found != tt.want {
+				t.Fatalf("email index created = %v, want %v", found, tt.want)
+			}
+
+			if !tt.want {
+				// Running it later, e.g. from a deploy step, creates the indexes
+				if err := m.EnsureIndexes(); err != nil {
+					t.Fatalf("EnsureIndexes: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/berkkaradalan/CoreGo/database"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// invite is a stored signup invitation; only the token hash is persisted
+type invite struct {
+	Email     string    `bson:"email"`
+	TokenHash string    `bson:"token_hash"`
+	CreatedAt time.Time `bson:"created_at"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// CreateInvite issues an invite token for email, valid for ttl (default: 7 days).
+// With Config.RequireInvite set, Signup only succeeds with a matching invite.
+func (m *Manager) CreateInvite(email string, ttl time.Duration) (string, error) {
+	if email == "" {
+		return "", errors.New("email is required")
+	}
+	if ttl <= 0 {
+		ttl = 7 * 24 * time.Hour
+	}
+
+	token, err := generateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = m.db.InsertOne(m.config.Collections.Invites, invite{
+		Email:     strings.ToLower(email),
+		TokenHash: HashToken(token),
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return "", errors.New("failed to store invite")
+	}
+
+	return token, nil
+}
+
+// claimInvite deletes the unexpired invite for token and email and returns it,
+// so it can be restored if signup fails. Concurrent signups with the same
+// invite can't both claim it.
+func (m *Manager) claimInvite(ctx context.Context, token, email string) (map[string]any, error) {
+	if token == "" {
+		return nil, ErrInvalidInvite
+	}
+
+	filter := bson.M{
+		"token_hash": HashToken(token),
+		"email":      strings.ToLower(email),
+		"expires_at": bson.M{"$gt": time.Now()},
+	}
+
+	record, err := m.db.FindOneAndDeleteContext(ctx, m.config.Collections.Invites, filter, nil)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, ErrInvalidInvite
+	}
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// restoreInvite puts back an invite claimed by a signup that then failed
+func (m *Manager) restoreInvite(ctx context.Context, record map[string]any) error {
+	// Restore even if the signup was cancelled, or the invite is lost
+	_, err := m.db.InsertOneContext(context.WithoutCancel(ctx), m.config.Collections.Invites, record)
+	return err
+}
@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestSignupWithInvite(t *testing.T) {
+	m := newTestManager(t, &Config{RequireInvite: true})
+
+	tests := []struct {
+		name        string
+		inviteEmail string
+		ttl         time.Duration
+		signupEmail string
+		token       func(issued string) string
+		wantErr     error
+	}{
+		{"matching invite", "a@example.com", time.Hour, "a@example.com", func(s string) string { return s }, nil},
+		{"email differs in case", "b@example.com", time.Hour, "B@Example.com", func(s string) string { return s }, nil},
+		{"other email", "c@example.com", time.Hour, "d@example.com", func(s string) string { return s }, ErrInvalidInvite},
+		{"expired", "e@example.com", -time.Minute, "e@example.com", func(s string) string { return s }, ErrInvalidInvite},
+		{"unknown token", "f@example.com", time.Hour, "f@example.com", func(string) string { return "nope" }, ErrInvalidInvite},
+		{"no token", "g@example.com", time.Hour, "g@example.com", func(string) string { return "" }, ErrInvalidInvite},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := m.CreateInvite(tt.inviteEmail, time.Hour)
+			if err != nil {
+				t.Fatalf("CreateInvite: %v", err)
+			}
+			if tt.ttl < 0 {
+				// CreateInvite replaces a non-positive ttl with the default
+				m.db.UpdateOne(m.config.Collections.Invites, bson.M{"token_hash": HashToken(token)},
+					bson.M{"$set": bson.M{"expires_at": time.Now().Add(tt.ttl)}})
+			}
+
+			_, _, err = m.Signup(SignupRequest{Email: tt.signupEmail, Password: "correct horse battery", InviteToken: tt.token(token)})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Signup error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSignupInviteUsedOnce(t *testing.T) {
+	m := newTestManager(t, &Config{RequireInvite: true})
+
+	token, err := m.CreateInvite("race@example.com", time.Hour)
+	if err != nil {
+		t.Fatalf("CreateInvite: %v", err)
+	}
+
+	const attempts = 5
+	var wg sync.WaitGroup
+	errs := make(chan error, attempts)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := m.Signup(SignupRequest{Email: "race@example.com", Password: "correct horse battery", InviteToken: token})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("%d signups succeeded with one invite, want 1", succeeded)
+	}
+}
+
+func TestSignupRestoresInviteWhenInsertFails(t *testing.T) {
+	m := newTestManager(t, &Config{
+		RequireInvite: true,
+		Indexes:       []IndexSpec{{Fields: []string{"custom.code"}, Unique: true}},
+	})
+
+	signup := func(email string) error {
+		token, err := m.CreateInvite(email, time.Hour)
+		if err != nil {
+			t.Fatalf("CreateInvite: %v", err)
+		}
+		_, _, err = m.Signup(SignupRequest{
+			Email:       email,
+			Password:    "correct horse battery",
+			Custom:      map[string]any{"code": "taken"},
+			InviteToken: token,
+		})
+		return err
+	}
+
+	if err := signup("first@example.com"); err != nil {
+		t.Fatalf("first Signup: %v", err)
+	}
+	// The unique custom.code index rejects the insert after the invite is claimed
+	if err := signup("second@example.com"); err == nil {
+		t.Fatal("second Signup succeeded despite the duplicate code")
+	}
+
+	count, err := m.db.Collection(m.config.Collections.Invites).CountDocuments(t.Context(), bson.M{"email": "second@example.com"})
+	if err != nil {
+		t.Fatalf("count invites: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("%d invites left for the failed signup, want 1", count)
+	}
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"fmt"
+	"net/smtp"
+	"strings"
+)
+
+// Mailer delivers emails such as verification and password reset links
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// NoopMailer discards every email. It is the default when no Mailer is configured,
+// in which case callers deliver the returned tokens themselves.
+type NoopMailer struct{}
+
+func (NoopMailer) Send(to, subject, body string) error {
+	return nil
+}
+
+// SMTPMailer sends plain text emails through an SMTP server
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+func (s *SMTPMailer) Send(to, subject, body string) error {
+	addr := fmt.Sprintf("%s:%d", s.Host, s.Port)
+
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+
+	msg := strings.Join([]string{
+		"From: " + s.From,
+		"To: " + to,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+
+	return smtp.SendMail(addr, auth, s.From, []string{to}, []byte(msg))
+}
@@ -0,0 +1,17 @@
+package auth
+
+// Metrics receives counters for auth operations, e.g. to back Prometheus collectors
+type Metrics interface {
+	IncSignup()
+	IncLogin()
+	IncLoginFailure()
+	IncTokenValidation(success bool)
+}
+
+// NoopMetrics discards every counter. It is the default when no Metrics is configured.
+type NoopMetrics struct{}
+
+func (NoopMetrics) IncSignup()                      {}
+func (NoopMetrics) IncLogin()                       {}
+func (NoopMetrics) IncLoginFailure()                {}
+func (NoopMetrics) IncTokenValidation(success bool) {}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"sync"
+	"testing"
+)
+
+type metricCounts struct {
+	signups, logins, loginFailures, validTokens, invalidTokens int
+}
+
+// countingMetrics counts every call
+type countingMetrics struct {
+	mu     sync.Mutex
+	counts metricCounts
+}
+
+func (c *countingMetrics) IncSignup()       { c.add(func(n *metricCounts) { n.signups++ }) }
+func (c *countingMetrics) IncLogin()        { c.add(func(n *metricCounts) { n.logins++ }) }
+func (c *countingMetrics) IncLoginFailure() { c.add(func(n *metricCounts) { n.loginFailures++ }) }
+func (c *countingMetrics) IncTokenValidation(success bool) {
+	if success {
+		c.add(func(n *metricCounts) { n.validTokens++ })
+	} else {
+		c.add(func(n *metricCounts) { n.invalidTokens++ })
+	}
+}
+
+func (c *countingMetrics) add(inc func(*metricCounts)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	inc(&c.counts)
+}
+
+func (c *countingMetrics) snapshot() metricCounts {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.counts
+}
+
+func TestMetrics(t *testing.T) {
+	metrics := &countingMetrics{}
+	m := newTestManager(t, &Config{Metrics: metrics})
+
+	tests := []struct {
+		name   string
+		action func()
+		want   metricCounts
+	}{
+		{"signup", func() { mustSignup(t, m, "metrics@example.com", "correct horse battery") }, metricCounts{signups: 1}},
+		{"login", func() { m.Login(LoginRequest{Email: "metrics@example.com", Password: "correct horse battery"}) }, metricCounts{logins: 1}},
+		{"wrong password", func() { m.Login(LoginRequest{Email: "metrics@example.com", Password: "wrong"}) }, metricCounts{loginFailures: 1}},
+		{"unknown email", func() { m.Login(LoginRequest{Email: "nobody@example.com", Password: "wrong"}) }, metricCounts{loginFailures: 1}},
+		{"valid token", func() {
+			_, token, _ := m.Login(LoginRequest{Email: "metrics@example.com", Password: "correct horse battery"})
+			m.ValidateToken(token)
+		}, metricCounts{logins: 1, validTokens: 1}},
+		{"invalid token", func() { m.ValidateToken("not-a-token") }, metricCounts{invalidTokens: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := metrics.snapshot()
+			tt.action()
+			after := metrics.snapshot()
+
+			got := metricCounts{
+				signups:       after.signups - before.signups,
+				logins:        after.logins - before.logins,
+				loginFailures: after.loginFailures - before.loginFailures,
+				validTokens:   after.validTokens - before.validTokens,
+				invalidTokens: after.invalidTokens - before.invalidTokens,
+			}
+			if got != tt.want {
+				t.Fatalf("counters changed by %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,39 +1,317 @@
 package auth
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-// Middleware returns auth middleware for protected routes
+// Middleware returns auth middleware for protected routes. Each request
+// loads the user to check the token version (see Config.UserCache), and while
+// any token is revoked with RevokeToken, also queries the blacklist.
 func (m *Manager) Middleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(401, gin.H{"error": "authorization header is required"})
-			c.Abort()
+		token := ""
+		if authHeader != "" {
+			parts := strings.Split(authHeader, " ")
+			if len(parts) != 2 || parts[0] != "Bearer" {
+				m.unauthorized(c, CodeUnauthorized, "invalid authorization header format")
+				return
+			}
+			token = parts[1]
+		} else if token = m.tokenFromCookie(c); token == "" {
+			message := "authorization header is required"
+			if m.usesCookie() {
+				message = "authorization header or token cookie is required"
+			}
+			m.unauthorized(c, CodeUnauthorized, message)
+			return
+		} else if m.csrfHeaderMissing(c) {
+			m.forbidden(c, "missing "+m.config.Cookie.CSRFHeader+" header")
 			return
 		}
 
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(401, gin.H{"error": "invalid authorization header format"})
-			c.Abort()
+		claims, user, err := m.validateToken(c.Request.Context(), token)
+		if err != nil {
+			m.unauthorized(c, CodeInvalidToken, "invalid or expired token")
 			return
 		}
 
-		token := parts[1]
+		c.Set("userID", claims["user_id"].(string))
+		if jti, ok := claims["jti"].(string); ok {
+			c.Set("tokenID", jti)
+		}
+		if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+			c.Set("tokenExpiresAt", exp.Time)
+		}
+		if user != nil {
+			// Reused by RequireRole instead of loading the user again
+			c.Set("user", user)
+		}
+
+		c.Next()
+	}
+}
+
+// RequireRole allows only users whose Role is one of roles and responds 403
+// to everyone else. Use it after Middleware, which handles the 401 cases.
+// It reads the current role from the user Middleware loaded for the request.
+func (m *Manager) RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := UserIDFromContext(c)
+		if !ok {
+			m.unauthorized(c, CodeUnauthorized, "unauthorized")
+			return
+		}
 
-		userID, err := m.ValidateToken(token)
+		user, err := m.contextUser(c, userID)
+		if errors.Is(err, ErrUserNotFound) {
+			m.unauthorized(c, CodeUnauthorized, "unauthorized")
+			return
+		}
 		if err != nil {
-			c.JSON(401, gin.H{"error": "invalid or expired token"})
-			c.Abort()
+			RespondError(c, 500, CodeInternal, "failed to load user")
 			return
 		}
 
-		c.Set("userID", userID)
+		for _, role := range roles {
+			if user.Role != "" && user.Role == role {
+				c.Next()
+				return
+			}
+		}
+		m.forbidden(c, "insufficient role")
+	}
+}
+
+// RequireAdmin is RequireRole for Config.AdminRole
+func (m *Manager) RequireAdmin() gin.HandlerFunc {
+	return m.RequireRole(m.config.AdminRole)
+}
+
+// UserIDFromContext returns the authenticated user ID set by Middleware.
+// ok is false if the value is missing or not a string.
+func UserIDFromContext(c *gin.Context) (string, bool) {
+	value, exists := c.Get("userID")
+	if !exists {
+		return "", false
+	}
+	userID, ok := value.(string)
+	if !ok || userID == "" {
+		return "", false
+	}
+	return userID, true
+}
+
+// contextUser returns the user Middleware loaded while validating the token,
+// or loads it when there is none, e.g. for test tokens
+func (m *Manager) contextUser(c *gin.Context, userID string) (*User, error) {
+	if value, exists := c.Get("user"); exists {
+		if user, ok := value.(*User); ok && user.ID == userID {
+			return user, nil
+		}
+	}
+	return m.GetUserByIDContext(c.Request.Context(), userID)
+}
+
+// MaxBodyBytes limits the request body size, responding 413 when exceeded.
+// Requests with a known Content-Length are rejected up front; chunked bodies
+// fail once the limit is reached while being read.
+func MaxBodyBytes(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > limit {
+			RespondError(c, 413, CodePayloadTooLarge, "request body too large")
+			return
+		}
 
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
 		c.Next()
 	}
-}
\ No newline at end of file
+}
+
+// Timeout sets a deadline on the request context and responds 504 as soon
+// as it passes, even if the handler is still running. Output the handler writes
+// after that is discarded. The rest of the chain runs in its own goroutine,
+// and Timeout waits for it to return before finishing the request, so handlers
+// should pass c.Request.Context() to blocking calls to free the goroutine.
+func Timeout(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		writer := &timeoutWriter{ResponseWriter: c.Writer, header: c.Writer.Header().Clone(), ctx: ctx}
+		c.Writer = writer
+
+		done := make(chan any, 1)
+		go func() {
+			defer func() { done <- recover() }()
+			c.Next()
+		}()
+
+		var panicked any
+		select {
+		case panicked = <-done:
+		case <-ctx.Done():
+			writer.mu.Lock()
+			writer.expired()
+			writer.mu.Unlock()
+			// gin reuses c once this middleware returns
+			panicked = <-done
+		}
+
+		writer.finish()
+		c.Writer = writer.ResponseWriter
+		if panicked != nil {
+			// Re-raised here so Recovery middleware earlier in the chain sees it
+			panic(panicked)
+		}
+	}
+}
+
+// timeoutWriter guards the response so the 504 and late handler output
+// can't interleave. The handler sets headers on its own copy, which is
+// applied when it writes.
+type timeoutWriter struct {
+	gin.ResponseWriter
+	header   http.Header
+	ctx      context.Context
+	mu       sync.Mutex
+	timedOut bool
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+// applyHeader copies the handler's headers to the response until it is
+// sent; callers hold mu
+func (w *timeoutWriter) applyHeader() {
+	if w.ResponseWriter.Written() {
+		return
+	}
+	dst := w.ResponseWriter.Header()
+	clear(dst)
+	for key, values := range w.header {
+		dst[key] = values
+	}
+}
+
+// expired reports whether the deadline has passed. The first call after it
+// writes the 504, unless the handler already started its own response, so
+// every later write is dropped. Callers hold mu.
+func (w *timeoutWriter) expired() bool {
+	if w.timedOut {
+		return true
+	}
+	if !errors.Is(w.ctx.Err(), context.DeadlineExceeded) {
+		return false
+	}
+
+	w.timedOut = true
+	if w.ResponseWriter.Written() {
+		return true
+	}
+
+	body, _ := json.Marshal(ErrorResponse{Error: ErrorDetail{Code: CodeTimeout, Message: "request timed out"}})
+	header := w.ResponseWriter.Header()
+	clear(header)
+	header.Set("Content-Type", "application/json; charset=utf-8")
+	// Lets the client read the whole response while the handler still runs
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	w.ResponseWriter.WriteHeader(http.StatusGatewayTimeout)
+	w.ResponseWriter.Write(body)
+	w.ResponseWriter.Flush()
+	return true
+}
+
+// finish sends the 504 if the deadline passed before the handler wrote
+// anything, or applies headers it set without writing a body
+func (w *timeoutWriter) finish() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.expired() {
+		w.applyHeader()
+	}
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.expired() {
+		w.applyHeader()
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.expired() {
+		w.applyHeader()
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *timeoutWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.expired() {
+		return 0, http.ErrHandlerTimeout
+	}
+	w.applyHeader()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.expired() {
+		return 0, http.ErrHandlerTimeout
+	}
+	w.applyHeader()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *timeoutWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.expired() {
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *timeoutWriter) Status() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.ResponseWriter.Status()
+}
+
+func (w *timeoutWriter) Size() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.ResponseWriter.Size()
+}
+
+func (w *timeoutWriter) Written() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.ResponseWriter.Written()
+}
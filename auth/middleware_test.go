@@ -0,0 +1,333 @@
+package auth
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		handler    func(release <-chan struct{}) gin.HandlerFunc
+		wantStatus int
+		wantBody   string
+		wantHeader string
+	}{
+		{
+			name: "fast handler",
+			handler: func(<-chan struct{}) gin.HandlerFunc {
+				return func(c *gin.Context) {
+					c.Header("X-Handler", "yes")
+					c.String(200, "ok")
+				}
+			},
+			wantStatus: 200,
+			wantBody:   "ok",
+			wantHeader: "yes",
+		},
+		{
+			name: "header without body",
+			handler: func(<-chan struct{}) gin.HandlerFunc {
+				return func(c *gin.Context) {
+					c.Header("X-Handler", "yes")
+					c.Status(204)
+				}
+			},
+			wantStatus: 204,
+			wantHeader: "yes",
+		},
+		{
+			name: "handler honouring ctx",
+			handler: func(<-chan struct{}) gin.HandlerFunc {
+				return func(c *gin.Context) {
+					<-c.Request.Context().Done()
+					c.String(200, "late")
+				}
+			},
+			wantStatus: 504,
+			wantBody:   CodeTimeout,
+		},
+		{
+			name: "slow handler",
+			handler: func(<-chan struct{}) gin.HandlerFunc {
+				return func(c *gin.Context) {
+					time.Sleep(100 * time.Millisecond)
+					c.String(200, "late")
+				}
+			},
+			wantStatus: 504,
+			wantBody:   CodeTimeout,
+		},
+		{
+			name: "hung handler",
+			handler: func(release <-chan struct{}) gin.HandlerFunc {
+				return func(c *gin.Context) {
+					c.Header("X-Handler", "yes")
+					<-release // ignores ctx
+					c.String(200, "late")
+				}
+			},
+			wantStatus: 504,
+			wantBody:   CodeTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release := make(chan struct{})
+			router := gin.New()
+			router.GET("/", Timeout(50*time.Millisecond), tt.handler(release))
+
+			server := httptest.NewServer(router)
+			defer server.Close()
+			defer close(release)
+
+			client := &http.Client{Timeout: 2 * time.Second}
+			start := time.Now()
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Fatalf("GET: %v", err)
+			}
+			defer resp.Body.Close()
+			body := new(bytes.Buffer)
+			if _, err := body.ReadFrom(resp.Body); err != nil {
+				t.Fatalf("read body: %v", err)
+			}
+
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("response took %v", elapsed)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if !strings.Contains(body.String(), tt.wantBody) {
+				t.Errorf("body = %q, want it to contain %q", body.String(), tt.wantBody)
+			}
+			if got := resp.Header.Get("X-Handler"); got != tt.wantHeader {
+				t.Errorf("X-Handler = %q, want %q", got, tt.wantHeader)
+			}
+		})
+	}
+}
+
+func TestTimeoutPanicReachesRecovery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(gin.Recovery())
+	router.GET("/", Timeout(time.Second), func(c *gin.Context) {
+		panic("boom")
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != 500 {
+		t.Fatalf("status = %d, want 500", w.Code)
+	}
+}
+
+func TestMiddlewareCSRFHeader(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// The token is never valid, so requests passing the CSRF check get 401
+	tests := []struct {
+		name       string
+		method     string
+		cookie     bool
+		header     string
+		disable    bool
+		wantStatus int
+	}{
+		{"cookie POST without header", "POST", true, "", false, 403},
+		{"cookie DELETE without header", "DELETE", true, "", false, 403},
+		{"cookie POST with header", "POST", true, "X-Requested-With", false, 401},
+		{"cookie POST with other header", "POST", true, "X-Other", false, 403},
+		{"cookie GET without header", "GET", true, "", false, 401},
+		{"bearer POST without header", "POST", false, "", false, 401},
+		{"check disabled", "POST", true, "", true, 401},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newOfflineManager(t, &Config{
+				TokenDelivery: DeliverCookie,
+				Cookie:        CookieConfig{DisableCSRFCheck: tt.disable},
+			})
+			router := gin.New()
+			router.Handle(tt.method, "/", m.Middleware(), func(c *gin.Context) { c.Status(200) })
+
+			req := httptest.NewRequest(tt.method, "/", nil)
+			if tt.cookie {
+				req.AddCookie(&http.Cookie{Name: m.config.Cookie.Name, Value: "not-a-token"})
+			} else {
+				req.Header.Set("Authorization", "Bearer not-a-token")
+			}
+			if tt.header != "" {
+				req.Header.Set(tt.header, "1")
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestUserIDFromContext(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := newOfflineManager(t, &Config{})
+
+	tests := []struct {
+		name       string
+		value      any
+		set        bool
+		wantID     string
+		wantOK     bool
+		wantStatus int
+	}{
+		{"missing", nil, false, "", false, 401},
+		{"not a string", 42, true, "", false, 401},
+		{"empty", "", true, "", false, 401},
+		{"set", "user-1", true, "user-1", true, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			if tt.set {
+				c.Set("userID", tt.value)
+			}
+			userID, ok := UserIDFromContext(c)
+			if userID != tt.wantID || ok != tt.wantOK {
+				t.Fatalf("UserIDFromContext = %q, %v, want %q, %v", userID, ok, tt.wantID, tt.wantOK)
+			}
+			if tt.wantOK {
+				return
+			}
+
+			// Handlers respond 401 instead of panicking on a bad value
+			router := gin.New()
+			router.GET("/", func(c *gin.Context) {
+				if tt.set {
+					c.Set("userID", tt.value)
+				}
+			}, m.GetProfileHandler())
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("GetProfileHandler status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestCustomAuthResponses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := newOfflineManager(t, &Config{
+		OnUnauthorized: func(c *gin.Context, code, message string) {
+			c.Redirect(302, "/login?reason="+code)
+		},
+		OnForbidden: func(c *gin.Context, message string) {
+			c.String(403, "nope: "+message)
+		},
+	})
+
+	tests := []struct {
+		name       string
+		handler    gin.HandlerFunc
+		header     string
+		wantStatus int
+		wantBody   string
+		wantLoc    string
+	}{
+		{"missing token", m.Middleware(), "", 302, "", "/login?reason=unauthorized"},
+		{"invalid token", m.Middleware(), "Bearer not-a-jwt", 302, "", "/login?reason=invalid_token"},
+		{"role without login", m.RequireRole("admin"), "", 302, "", "/login?reason=unauthorized"},
+		{"forbidden", func(c *gin.Context) { m.forbidden(c, "admins only") }, "", 403, "nope: admins only", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reached := false
+			router := gin.New()
+			router.GET("/", tt.handler, func(c *gin.Context) { reached = true })
+
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus || w.Header().Get("Location") != tt.wantLoc {
+				t.Fatalf("response = %d to %q, want %d to %q", w.Code, w.Header().Get("Location"), tt.wantStatus, tt.wantLoc)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Fatalf("body = %q, want %q", w.Body.String(), tt.wantBody)
+			}
+			if reached {
+				t.Fatal("the handler chain wasn't aborted")
+			}
+		})
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := newTestManager(t, &Config{})
+	admin := mustSignup(t, m, "admin@example.com", "correct horse battery")
+	if err := m.db.UpdateOne(m.config.DatabaseName, bson.M{"_id": mustObjectID(t, admin.ID)}, bson.M{"$set": bson.M{"role": "admin"}}); err != nil {
+		t.Fatalf("set role: %v", err)
+	}
+	member := mustSignup(t, m, "member@example.com", "correct horse battery")
+
+	tests := []struct {
+		name       string
+		userID     string
+		roles      []string
+		wantStatus int
+	}{
+		{"matching role", admin.ID, []string{"admin"}, 200},
+		{"one of several roles", admin.ID, []string{"editor", "admin"}, 200},
+		{"other role", admin.ID, []string{"editor"}, 403},
+		{"no role", member.ID, []string{"admin"}, 403},
+		{"no role, empty role allowed", member.ID, []string{""}, 403},
+		{"unknown user", "000000000000000000000000", []string{"admin"}, 401},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/", func(c *gin.Context) { c.Set("userID", tt.userID) }, m.RequireRole(tt.roles...), func(c *gin.Context) {
+				c.String(200, "ok")
+			})
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+}
+
+func mustObjectID(t *testing.T, id string) primitive.ObjectID {
+	t.Helper()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		t.Fatalf("ObjectIDFromHex(%s): %v", id, err)
+	}
+	return objID
+}
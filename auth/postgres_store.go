@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/berkkaradalan/CoreGo/database"
+	"github.com/jackc/pgx/v5"
+)
+
+// UserStore keeps the users that signup, login and token validation work
+// with, instead of the MongoDB users collection; see Config.UserStore.
+// Lookups return ErrUserNotFound when there is no match, and CreateUser
+// returns ErrEmailExists when the email is taken.
+type UserStore interface {
+	CreateUser(user *User) (string, error)
+	GetUserByID(id string) (*User, error)
+	GetUserByEmail(email string) (*User, error)
+}
+
+// PostgresStoreConfig configures a PostgresUserStore
+type PostgresStoreConfig struct {
+	Table   string // default: "users", may be schema qualified ("auth.users")
+	Columns PostgresColumns
+}
+
+// PostgresColumns maps User fields to column names, so the store can work
+// with an existing table. Empty fields use the defaults.
+type PostgresColumns struct {
+	ID       string // default: "id"
+	Email    string // default: "email"
+	Password string // default: "password", e.g. "password_hash"
+	Custom   string // default: "custom", a JSONB column
+}
+
+// PostgresUserStore keeps auth users in a Postgres table. The columns
+// mirror the fields of User. It is a UserStore.
+type PostgresUserStore struct {
+	db      *database.PostgresDB
+	table   string
+	columns PostgresColumns
+}
+
+// NewPostgresUserStore returns a store for the configured table and columns
+func NewPostgresUserStore(db *database.PostgresDB, config PostgresStoreConfig) *PostgresUserStore {
+	if config.Table == "" {
+		config.Table = "users"
+	}
+	config.Columns.setDefaults()
+
+	return &PostgresUserStore{
+		db:      db,
+		table:   config.Table,
+		columns: config.Columns,
+	}
+}
+
+// setDefaults fills empty column names
+func (c *PostgresColumns) setDefaults() {
+	if c.ID == "" {
+		c.ID = "id"
+	}
+	if c.Email == "" {
+		c.Email = "email"
+	}
+	if c.Password == "" {
+		c.Password = "password"
+	}
+	if c.Custom == "" {
+		c.Custom = "custom"
+	}
+}
+
+// EnsureSchema creates the users table if it doesn't exist.
+// It is safe to call on every start; an existing table is left unchanged.
+func (s *PostgresUserStore) EnsureSchema() error {
+	_, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS ` + s.tableIdent() + ` (
+		` + ident(s.columns.ID) + ` TEXT PRIMARY KEY DEFAULT gen_random_uuid()::text,
+		` + ident(s.columns.Email) + ` TEXT NOT NULL UNIQUE,
+		` + ident(s.columns.Password) + ` TEXT NOT NULL,
+		` + ident(s.columns.Custom) + ` JSONB NOT NULL DEFAULT '{}',
+		email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+		role           TEXT NOT NULL DEFAULT '',
+		token_version  INTEGER NOT NULL DEFAULT 0,
+		created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		deleted_at     TIMESTAMPTZ
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create %s table: %w", s.table, err)
+	}
+
+	return nil
+}
+
+// CreateUser inserts the user's email, password hash and custom data and
+// returns the new ID, or ErrEmailExists if the email column's unique
+// constraint rejects it
+func (s *PostgresUserStore) CreateUser(user *User) (string, error) {
+	custom, err := json.Marshal(user.Custom)
+	if err != nil {
+		return "", err
+	}
+	if user.Custom == nil {
+		custom = []byte("{}")
+	}
+
+	rows, err := s.db.QueryPrimary(
+		`INSERT INTO `+s.tableIdent()+` (`+ident(s.columns.Email)+`, `+ident(s.columns.Password)+`, `+ident(s.columns.Custom)+`)
+		VALUES ($1, $2, $3) RETURNING `+ident(s.columns.ID)+`::text AS id`,
+		user.Email, user.Password, string(custom),
+	)
+	var constraint *database.ConstraintError
+	if errors.As(err, &constraint) && errors.Is(err, database.ErrUniqueViolation) && constraint.Column == s.columns.Email {
+		return "", ErrEmailExists
+	}
+	if err != nil {
+		return "", err
+	}
+
+	id, _ := rows[0]["id"].(string)
+	return id, nil
+}
+
+// GetUserByID finds a user by ID, returning ErrUserNotFound if there is none
+func (s *PostgresUserStore) GetUserByID(id string) (*User, error) {
+	return s.getUser(ident(s.columns.ID)+"::text", id)
+}
+
+// GetUserByEmail finds a user by email, returning ErrUserNotFound if there is none
+func (s *PostgresUserStore) GetUserByEmail(email string) (*User, error) {
+	return s.getUser(ident(s.columns.Email), email)
+}
+
+// getUser loads the user whose where expression equals value
+func (s *PostgresUserStore) getUser(where, value string) (*User, error) {
+	rows, err := s.db.QueryPrimary(
+		`SELECT `+ident(s.columns.ID)+`::text AS id, `+ident(s.columns.Email)+` AS email, `+
+			ident(s.columns.Password)+` AS password, `+ident(s.columns.Custom)+` AS custom
+		FROM `+s.tableIdent()+` WHERE `+where+` = $1`,
+		value,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrLookupFailed, err)
+	}
+	if len(rows) == 0 {
+		return nil, ErrUserNotFound
+	}
+
+	user := &User{}
+	user.ID, _ = rows[0]["id"].(string)
+	user.Email, _ = rows[0]["email"].(string)
+	user.Password, _ = rows[0]["password"].(string)
+	if custom, ok := rows[0]["custom"].(map[string]any); ok {
+		user.Custom = custom
+	} else if rows[0]["custom"] != nil {
+		return nil, errors.New("custom column must be JSON or JSONB")
+	}
+
+	return user, nil
+}
+
+// tableIdent returns the quoted, possibly schema qualified table name
+func (s *PostgresUserStore) tableIdent() string {
+	return pgx.Identifier(strings.Split(s.table, ".")).Sanitize()
+}
+
+// ident quotes a column name
+func ident(name string) string {
+	return pgx.Identifier{name}.Sanitize()
+}
@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestEnsureSchema(t *testing.T) {
+	db := newTestPostgres(t)
+	table := newTestTable(t, db)
+	store := NewPostgresUserStore(db, PostgresStoreConfig{Table: table})
+
+	// Safe to run on every start
+	for i := 1; i <= 2; i++ {
+		if err := store.EnsureSchema(); err != nil {
+			t.Fatalf("EnsureSchema call %d: %v", i, err)
+		}
+	}
+
+	rows, err := db.QueryPrimary(
+		`SELECT column_name::text AS name FROM information_schema.columns WHERE table_name = $1 ORDER BY ordinal_position`,
+		table,
+	)
+	if err != nil {
+		t.Fatalf("list columns: %v", err)
+	}
+	var columns []string
+	for _, row := range rows {
+		name, _ := row["name"].(string)
+		columns = append(columns, name)
+	}
+	want := []string{"id", "email", "password", "custom", "email_verified", "role", "token_version", "created_at", "updated_at", "deleted_at"}
+	if !slices.Equal(columns, want) {
+		t.Fatalf("columns = %v, want %v", columns, want)
+	}
+}
+
+func TestPostgresUserStoreColumns(t *testing.T) {
+	columns := PostgresColumns{ID: "user_id", Email: "mail", Password: "password_hash", Custom: "profile"}
+
+	tests := []struct {
+		name   string
+		create func(store *PostgresUserStore, table string) error
+	}{
+		{"existing table", func(store *PostgresUserStore, table string) error {
+			_, err := store.db.Exec(`CREATE TABLE ` + table + ` (
+				user_id       BIGSERIAL PRIMARY KEY,
+				mail          TEXT NOT NULL UNIQUE,
+				password_hash TEXT NOT NULL,
+				profile       JSONB
+			)`)
+			return err
+		}},
+		{"created by EnsureSchema", func(store *PostgresUserStore, _ string) error {
+			return store.EnsureSchema()
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestPostgres(t)
+			table := newTestTable(t, db)
+			store := NewPostgresUserStore(db, PostgresStoreConfig{Table: table, Columns: columns})
+			if err := tt.create(store, table); err != nil {
+				t.Fatalf("create table: %v", err)
+			}
+
+			want := &User{Email: "columns@example.com", Password: "password-hash", Custom: map[string]any{"name": "Ada"}}
+			id, err := store.CreateUser(want)
+			if err != nil {
+				t.Fatalf("CreateUser: %v", err)
+			}
+			want.ID = id
+
+			for _, lookup := range []func() (*User, error){
+				func() (*User, error) { return store.GetUserByID(id) },
+				func() (*User, error) { return store.GetUserByEmail(want.Email) },
+			} {
+				got, err := lookup()
+				if err != nil {
+					t.Fatalf("lookup: %v", err)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Fatalf("user = %+v, want %+v", got, want)
+				}
+			}
+
+			if _, err := store.GetUserByEmail("missing@example.com"); !errors.Is(err, ErrUserNotFound) {
+				t.Fatalf("missing user: error = %v, want ErrUserNotFound", err)
+			}
+			if _, err := store.CreateUser(&User{Email: want.Email, Password: "other-hash"}); !errors.Is(err, ErrEmailExists) {
+				t.Fatalf("duplicate CreateUser error = %v, want ErrEmailExists", err)
+			}
+		})
+	}
+}
+
+func TestManagerWithPostgresUserStore(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := newTestPostgres(t)
+	store := NewPostgresUserStore(db, PostgresStoreConfig{
+		Table:   newTestTable(t, db),
+		Columns: PostgresColumns{ID: "user_id", Email: "mail", Password: "password_hash", Custom: "profile"},
+	})
+	if err := store.EnsureSchema(); err != nil {
+		t.Fatalf("EnsureSchema: %v", err)
+	}
+
+	// No MongoDB at all: every step below goes through the store
+	m, err := New(&Config{Secret: "test-secret", UserStore: store}, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	user, _, err := m.Signup(SignupRequest{Email: "store@example.com", Password: "correct horse battery"})
+	if err != nil {
+		t.Fatalf("Signup: %v", err)
+	}
+	if _, _, err := m.Signup(SignupRequest{Email: "store@example.com", Password: "correct horse battery"}); !errors.Is(err, ErrEmailExists) {
+		t.Fatalf("second Signup error = %v, want ErrEmailExists", err)
+	}
+
+	stored, err := store.GetUserByEmail("store@example.com")
+	if err != nil {
+		t.Fatalf("user not in the store: %v", err)
+	}
+	if stored.ID != user.ID || !VerifyPassword(stored.Password, "correct horse battery") {
+		t.Fatalf("stored user = %+v, want ID %s with the hashed password", stored, user.ID)
+	}
+
+	if _, _, err := m.Login(LoginRequest{Email: "store@example.com", Password: "wrong"}); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Login with a wrong password = %v, want ErrInvalidCredentials", err)
+	}
+	_, token, err := m.Login(LoginRequest{Email: "store@example.com", Password: "correct horse battery"})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	router := gin.New()
+	router.GET("/me", m.Middleware(), func(c *gin.Context) {
+		userID, _ := UserIDFromContext(c)
+		c.String(200, userID)
+	})
+	req := httptest.NewRequest("GET", "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != 200 || w.Body.String() != user.ID {
+		t.Fatalf("GET /me = %d %q, want 200 %q", w.Code, w.Body.String(), user.ID)
+	}
+}
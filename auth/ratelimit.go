@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter is an in-memory fixed-window counter keyed by string.
+// Counts are per process; instances behind a load balancer limit separately.
+type rateLimiter struct {
+	mu        sync.Mutex
+	limit     int
+	window    time.Duration
+	hits      map[string]*rateWindow
+	lastSweep time.Time
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{
+		limit:  limit,
+		window: window,
+		hits:   make(map[string]*rateWindow),
+	}
+}
+
+// allow records a hit for key and reports whether it is within the limit
+func (l *rateLimiter) allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	w, ok := l.hits[key]
+	if !ok || now.Sub(w.start) >= l.window {
+		w = &rateWindow{start: now}
+		l.hits[key] = w
+	}
+	w.count++
+	return w.count <= l.limit
+}
+
+// blocked reports whether key has reached the limit in its current window,
+// without recording a hit
+func (l *rateLimiter) blocked(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	w, ok := l.hits[key]
+	return ok && time.Since(w.start) < l.window && w.count >= l.limit
+}
+
+// reset forgets the hits for key, e.g. after a successful login
+func (l *rateLimiter) reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.hits, key)
+}
+
+// sweep drops expired windows, at most once per window
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.window {
+		return
+	}
+	for key, w := range l.hits {
+		if now.Sub(w.start) >= l.window {
+			delete(l.hits, key)
+		}
+	}
+	l.lastSweep = now
+}
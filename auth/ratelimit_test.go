@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimiter(t *testing.T) {
+	tests := []struct {
+		name        string
+		window      time.Duration
+		ops         string // a: allow, r: reset, w: wait out the window
+		wantAllowed string // result of each allow, in order
+		wantBlocked bool
+	}{
+		{"under the limit", time.Hour, "aa", "yy", false},
+		{"at the limit", time.Hour, "aaa", "yyy", true},
+		{"over the limit", time.Hour, "aaaa", "yyyn", true},
+		{"reset", time.Hour, "aaara", "yyyy", false},
+		{"window expires", 20 * time.Millisecond, "aaawa", "yyyy", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newRateLimiter(3, tt.window)
+			var allowed strings.Builder
+			for _, op := range tt.ops {
+				switch op {
+				case 'a':
+					if l.allow("key") {
+						allowed.WriteByte('y')
+					} else {
+						allowed.WriteByte('n')
+					}
+				case 'r':
+					l.reset("key")
+				case 'w':
+					time.Sleep(2 * tt.window)
+				}
+			}
+
+			if allowed.String() != tt.wantAllowed {
+				t.Fatalf("allow results = %s, want %s", allowed.String(), tt.wantAllowed)
+			}
+			if got := l.blocked("key"); got != tt.wantBlocked {
+				t.Fatalf("blocked = %v, want %v", got, tt.wantBlocked)
+			}
+			if l.blocked("other") {
+				t.Fatal("an unrelated key is blocked")
+			}
+		})
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	m := newTestManager(t, &Config{MaxFailedLogins: 2, LockoutDuration: time.Hour})
+	mustSignup(t, m, "locked@example.com", "correct horse battery")
+	mustSignup(t, m, "other@example.com", "correct horse battery")
+
+	tests := []struct {
+		email    string
+		password string
+		wantErr  error
+	}{
+		{"locked@example.com", "wrong", ErrInvalidCredentials},
+		{"locked@example.com", "correct horse battery", nil}, // success resets the count
+		{"locked@example.com", "wrong", ErrInvalidCredentials},
+		{"LOCKED@example.com", "wrong", ErrInvalidCredentials},
+		{"locked@example.com", "correct horse battery", ErrAccountLocked},
+		{"other@example.com", "correct horse battery", nil},
+		{"unknown@example.com", "wrong", ErrInvalidCredentials},
+		{"unknown@example.com", "wrong", ErrInvalidCredentials},
+		{"unknown@example.com", "wrong", ErrAccountLocked},
+	}
+
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%d %s", i, tt.email), func(t *testing.T) {
+			_, _, err := m.Login(LoginRequest{Email: tt.email, Password: tt.password})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Login error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoginHandlerRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := newTestManager(t, &Config{LoginRateLimit: 2, LoginRateWindow: time.Hour})
+	mustSignup(t, m, "limited@example.com", "correct horse battery")
+
+	router := gin.New()
+	router.POST("/login", m.LoginHandler())
+
+	tests := []struct {
+		ip         string
+		email      string
+		password   string
+		wantStatus int
+	}{
+		{"192.0.2.1", "a@example.com", "wrong", 401},
+		{"192.0.2.1", "b@example.com", "wrong", 401},
+		{"192.0.2.1", "limited@example.com", "correct horse battery", 429},
+		{"192.0.2.2", "limited@example.com", "correct horse battery", 200},
+	}
+
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%d %s", i, tt.ip), func(t *testing.T) {
+			body := fmt.Sprintf(`{"email":%q,"password":%q}`, tt.email, tt.password)
+			req := httptest.NewRequest("POST", "/login", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.RemoteAddr = tt.ip + ":1234"
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+}
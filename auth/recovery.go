@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RequestPasswordReset issues a reset token for the user and emails the link.
+// The token is also returned so apps without a Mailer can deliver it themselves.
+func (m *Manager) RequestPasswordReset(email string) (string, error) {
+	return m.RequestPasswordResetContext(context.Background(), email)
+}
+
+// RequestPasswordResetContext is like RequestPasswordReset but runs under ctx
+func (m *Manager) RequestPasswordResetContext(ctx context.Context, email string) (string, error) {
+	user, err := m.GetUserByEmailContext(ctx, email)
+	if err != nil {
+		return "", err
+	}
+
+	token, err := m.issueOneTimeToken(ctx, m.config.Collections.PasswordResets, user.ID, m.config.ResetTokenExpiry)
+	if err != nil {
+		return "", err
+	}
+
+	body := fmt.Sprintf(
+		"Use the link below to reset your password:\n\n%s\n\nThe link expires in %s. If you didn't request this, you can ignore this email.",
+		buildLink(m.config.ResetPasswordURL, token), m.config.ResetTokenExpiry,
+	)
+	if err := m.config.Mailer.Send(user.Email, "Reset your password", body); err != nil {
+		return "", fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return token, nil
+}
+
+// ResetPassword sets a new password using a reset token and revokes existing sessions
+func (m *Manager) ResetPassword(token, newPassword string) error {
+	_, err := m.resetPassword(token, newPassword)
+	return err
+}
+
+// resetPassword is ResetPassword returning the ID of the affected user
+func (m *Manager) resetPassword(token, newPassword string) (string, error) {
+	if err := validatePassword(newPassword); err != nil {
+		return "", err
+	}
+
+	userID, err := m.consumeOneTimeToken(m.config.Collections.PasswordResets, token)
+	if err != nil {
+		return "", err
+	}
+
+	hashedPassword, err := HashPassword(newPassword)
+	if err != nil {
+		return "", errors.New("failed to hash password")
+	}
+
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+
+	err = m.db.UpdateOne(
+		m.config.DatabaseName,
+		bson.M{"_id": objID},
+		bson.M{
+			"$set": bson.M{"password": hashedPassword},
+			"$inc": bson.M{"token_version": 1},
+		},
+	)
+	m.invalidateUser(userID)
+	if err != nil {
+		return "", errors.New("failed to reset password")
+	}
+
+	return userID, nil
+}
+
+// RequestEmailVerification issues a verification token and emails the link.
+// The token is also returned so apps without a Mailer can deliver it themselves.
+func (m *Manager) RequestEmailVerification(userID string) (string, error) {
+	user, err := m.GetUserByID(userID)
+	if err != nil {
+		return "", err
+	}
+	if user.EmailVerified {
+		return "", errors.New("email is already verified")
+	}
+
+	token, err := m.issueOneTimeToken(context.Background(), m.config.Collections.EmailVerifications, userID, m.config.VerificationTokenExpiry)
+	if err != nil {
+		return "", err
+	}
+
+	body := fmt.Sprintf(
+		"Use the link below to verify your email address:\n\n%s\n\nThe link expires in %s.",
+		buildLink(m.config.VerifyEmailURL, token), m.config.VerificationTokenExpiry,
+	)
+	if err := m.config.Mailer.Send(user.Email, "Verify your email", body); err != nil {
+		return "", fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return token, nil
+}
+
+// VerifyEmail marks the token's user as verified
+func (m *Manager) VerifyEmail(token string) error {
+	userID, err := m.consumeOneTimeToken(m.config.Collections.EmailVerifications, token)
+	if err != nil {
+		return err
+	}
+
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	err = m.db.UpdateOne(
+		m.config.DatabaseName,
+		bson.M{"_id": objID},
+		bson.M{"$set": bson.M{"email_verified": true}},
+	)
+	m.invalidateUser(userID)
+	if err != nil {
+		return errors.New("failed to verify email")
+	}
+
+	return nil
+}
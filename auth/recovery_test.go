@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordingMailer keeps every email it was asked to send
+type recordingMailer struct {
+	mu   sync.Mutex
+	sent []sentEmail
+}
+
+type sentEmail struct {
+	to, subject, body string
+}
+
+func (r *recordingMailer) Send(to, subject, body string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sent = append(r.sent, sentEmail{to, subject, body})
+	return nil
+}
+
+func TestBuildLink(t *testing.T) {
+	tests := []struct {
+		name string
+		base string
+		want string
+	}{
+		{"no base returns the token", "", "a+b/c"},
+		{"base without query", "https://app.example.com/reset", "https://app.example.com/reset?token=a%2Bb%2Fc"},
+		{"base with query", "https://app.example.com/reset?lang=en", "https://app.example.com/reset?lang=en&token=a%2Bb%2Fc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := buildLink(tt.base, "a+b/c"); got != tt.want {
+				t.Fatalf("buildLink = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPasswordResetFlow(t *testing.T) {
+	mailer := &recordingMailer{}
+	m := newTestManager(t, &Config{Mailer: mailer, ResetPasswordURL: "https://app.example.com/reset"})
+	mustSignup(t, m, "reset@example.com", "correct horse battery")
+
+	token, err := m.RequestPasswordReset("reset@example.com")
+	if err != nil {
+		t.Fatalf("RequestPasswordReset: %v", err)
+	}
+	if len(mailer.sent) != 1 || mailer.sent[0].to != "reset@example.com" || !strings.Contains(mailer.sent[0].body, buildLink(m.config.ResetPasswordURL, token)) {
+		t.Fatalf("sent = %+v, want one email with the reset link", mailer.sent)
+	}
+	if _, err := m.RequestPasswordReset("unknown@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("RequestPasswordReset for unknown email = %v, want ErrUserNotFound", err)
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		password string
+		wantErr  bool
+	}{
+		{"empty password", token, "", true},
+		{"wrong token", "not-a-token", "battery staple horse", true},
+		{"valid", token, "battery staple horse", false},
+		{"token used twice", token, "another password", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := m.ResetPassword(tt.token, tt.password); (err != nil) != tt.wantErr {
+				t.Fatalf("ResetPassword error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, _, err := m.Login(LoginRequest{Email: "reset@example.com", Password: "battery staple horse"}); err != nil {
+		t.Fatalf("Login with the new password: %v", err)
+	}
+}
+
+func TestEmailVerificationFlow(t *testing.T) {
+	mailer := &recordingMailer{}
+	m := newTestManager(t, &Config{Mailer: mailer})
+	user := mustSignup(t, m, "verify@example.com", "correct horse battery")
+
+	token, err := m.RequestEmailVerification(user.ID)
+	if err != nil {
+		t.Fatalf("RequestEmailVerification: %v", err)
+	}
+	if len(mailer.sent) != 1 || !strings.Contains(mailer.sent[0].body, token) {
+		t.Fatalf("sent = %+v, want one email with the token", mailer.sent)
+	}
+
+	tests := []struct {
+		name    string
+		action  func() error
+		wantErr bool
+	}{
+		{"wrong token", func() error { return m.VerifyEmail("not-a-token") }, true},
+		{"valid token", func() error { return m.VerifyEmail(token) }, false},
+		{"token used twice", func() error { return m.VerifyEmail(token) }, true},
+		{"already verified", func() error { _, err := m.RequestEmailVerification(user.ID); return err }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.action(); (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	verified, err := m.GetUserByID(user.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if !verified.EmailVerified {
+		t.Fatal("email not marked as verified")
+	}
+}
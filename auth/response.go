@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Error codes used in error responses
+const (
+	CodeBadRequest           = "bad_request"
+	CodeValidationFailed     = "validation_failed"
+	CodeUnauthorized         = "unauthorized"
+	CodeForbidden            = "forbidden"
+	CodeInvalidCredentials   = "invalid_credentials"
+	CodeInvalidToken         = "invalid_token"
+	CodeNotFound             = "not_found"
+	CodeMethodNotAllowed     = "method_not_allowed"
+	CodePayloadTooLarge      = "payload_too_large"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeTimeout              = "timeout"
+	CodeTooManyRequests      = "too_many_requests"
+	CodeInternal             = "internal_error"
+)
+
+// ErrorDetail is the body of a standard error response
+type ErrorDetail struct {
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+// ErrorResponse is the standard error envelope: {"error": {"code": ..., "message": ...}}
+type ErrorResponse struct {
+	Error ErrorDetail `json:"error"`
+}
+
+// RespondError writes a standard error envelope and aborts the handler chain
+func RespondError(c *gin.Context, status int, code, message string) {
+	c.AbortWithStatusJSON(status, ErrorResponse{
+		Error: ErrorDetail{Code: code, Message: message},
+	})
+}
+
+// unauthorized responds 401 through Config.OnUnauthorized, for requests
+// without valid credentials
+func (m *Manager) unauthorized(c *gin.Context, code, message string) {
+	if m.config.OnUnauthorized == nil {
+		RespondError(c, 401, code, message)
+		return
+	}
+	m.config.OnUnauthorized(c, code, message)
+	c.Abort()
+}
+
+// forbidden responds 403 through Config.OnForbidden, for authenticated
+// requests that aren't allowed
+func (m *Manager) forbidden(c *gin.Context, message string) {
+	if m.config.OnForbidden == nil {
+		RespondError(c, 403, CodeForbidden, message)
+		return
+	}
+	m.config.OnForbidden(c, message)
+	c.Abort()
+}
+
+// RespondValidationError writes a validation_failed envelope listing field errors
+func RespondValidationError(c *gin.Context, fields FieldErrors) {
+	c.AbortWithStatusJSON(400, ErrorResponse{
+		Error: ErrorDetail{Code: CodeValidationFailed, Message: "validation failed", Fields: fields},
+	})
+}
+
+// RespondSuccess writes a successful JSON response
+func RespondSuccess(c *gin.Context, status int, data any) {
+	c.JSON(status, data)
+}
+
+// CamelUser is the camelCase representation of a user in handler responses
+type CamelUser struct {
+	ID            string                 `json:"id"`
+	Email         string                 `json:"email"`
+	Custom        map[string]interface{} `json:"custom,omitempty"`
+	CreatedAt     time.Time              `json:"createdAt"`
+	EmailVerified bool                   `json:"emailVerified"`
+	Role          string                 `json:"role,omitempty"`
+}
+
+// CamelAuthResponse is the camelCase representation of AuthResponse
+type CamelAuthResponse struct {
+	User  CamelUser `json:"user"`
+	Token string    `json:"token,omitempty"`
+}
+
+// TokenResponse is the signup/login body for ShapeTokenOnly
+type TokenResponse struct {
+	Token string `json:"token,omitempty"`
+}
+
+// UserResponse is the signup/login body for ShapeUserOnly
+type UserResponse struct {
+	User any `json:"user"`
+}
+
+// userResponse renders a user in the configured key casing
+func (m *Manager) userResponse(user *PublicUser) any {
+	if m.config.ResponseCase != CaseCamel {
+		return user
+	}
+	return CamelUser{
+		ID:            user.ID,
+		Email:         user.Email,
+		Custom:        user.Custom,
+		CreatedAt:     user.CreatedAt,
+		EmailVerified: user.EmailVerified,
+		Role:          user.Role,
+	}
+}
+
+// responseToken adds the "Bearer " prefix to a token when BearerPrefix is set
+func (m *Manager) responseToken(token string) string {
+	if m.config.BearerPrefix && token != "" {
+		return "Bearer " + token
+	}
+	return token
+}
+
+// authResponse renders a user and token in the configured shape and key casing
+func (m *Manager) authResponse(user *User, token string) any {
+	token = m.responseToken(token)
+
+	switch m.config.ResponseShape {
+	case ShapeTokenOnly:
+		return TokenResponse{Token: token}
+	case ShapeUserOnly:
+		return UserResponse{User: m.userResponse(user.Public())}
+	}
+
+	if m.config.ResponseCase != CaseCamel {
+		return AuthResponse{User: *user, Token: token}
+	}
+	return CamelAuthResponse{
+		User:  m.userResponse(user.Public()).(CamelUser),
+		Token: token,
+	}
+}
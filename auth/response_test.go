@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestErrorEnvelope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := newOfflineManager(t, &Config{})
+
+	tests := []struct {
+		name       string
+		respond    func(c *gin.Context)
+		wantStatus int
+		wantBody   string
+	}{
+		{"error", func(c *gin.Context) { RespondError(c, 400, CodeBadRequest, "bad input") }, 400,
+			`{"error":{"code":"bad_request","message":"bad input"}}`},
+		{"validation error", func(c *gin.Context) { RespondValidationError(c, FieldErrors{"email": "is required"}) }, 400,
+			`{"error":{"code":"validation_failed","message":"validation failed","fields":{"email":"is required"}}}`},
+		{"unauthorized", func(c *gin.Context) { m.unauthorized(c, CodeInvalidToken, "token expired") }, 401,
+			`{"error":{"code":"invalid_token","message":"token expired"}}`},
+		{"forbidden", func(c *gin.Context) { m.forbidden(c, "admins only") }, 403,
+			`{"error":{"code":"forbidden","message":"admins only"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reached := false
+			router := gin.New()
+			router.GET("/", tt.respond, func(c *gin.Context) { reached = true })
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+			if w.Code != tt.wantStatus || w.Body.String() != tt.wantBody {
+				t.Fatalf("response = %d %s, want %d %s", w.Code, w.Body.String(), tt.wantStatus, tt.wantBody)
+			}
+			if reached {
+				t.Fatal("the handler chain wasn't aborted")
+			}
+		})
+	}
+}
+
+func TestAuthResponseShape(t *testing.T) {
+	user := &User{ID: "user-1", Email: "shape@example.com", Password: "password-hash"}
+
+	tests := []struct {
+		name     string
+		config   *Config
+		wantKeys []string
+	}{
+		{"default", &Config{}, []string{"token", "user"}},
+		{"user and token", &Config{ResponseShape: ShapeUserAndToken}, []string{"token", "user"}},
+		{"token only", &Config{ResponseShape: ShapeTokenOnly}, []string{"token"}},
+		{"user only", &Config{ResponseShape: ShapeUserOnly, TokenDelivery: DeliverCookie}, []string{"user"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newOfflineManager(t, tt.config)
+			body, err := json.Marshal(m.authResponse(user, "abc"))
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+
+			var got map[string]json.RawMessage
+			if err := json.Unmarshal(body, &got); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			if keys := slices.Sorted(maps.Keys(got)); !slices.Equal(keys, tt.wantKeys) {
+				t.Fatalf("keys = %v, want %v in %s", keys, tt.wantKeys, body)
+			}
+			if strings.Contains(string(body), user.Password) {
+				t.Fatalf("response contains the password hash: %s", body)
+			}
+		})
+	}
+}
+
+func TestResponseCase(t *testing.T) {
+	user := &User{
+		ID:            "user-1",
+		Email:         "case@example.com",
+		Password:      "hash",
+		Custom:        map[string]any{"first_name": "Ada"},
+		CreatedAt:     time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		EmailVerified: true,
+	}
+
+	tests := []struct {
+		name     string
+		caseName string
+		token    string
+		want     string
+	}{
+		{"snake by default", "", "tok",
+			`{"user":{"id":"user-1","email":"case@example.com","custom":{"first_name":"Ada"},"created_at":"2024-01-02T03:04:05Z","email_verified":true},"token":"tok"}`},
+		{"snake", CaseSnake, "tok",
+			`{"user":{"id":"user-1","email":"case@example.com","custom":{"first_name":"Ada"},"created_at":"2024-01-02T03:04:05Z","email_verified":true},"token":"tok"}`},
+		// Custom keys are the application's and keep their casing
+		{"camel", CaseCamel, "tok",
+			`{"user":{"id":"user-1","email":"case@example.com","custom":{"first_name":"Ada"},"createdAt":"2024-01-02T03:04:05Z","emailVerified":true},"token":"tok"}`},
+		{"camel without token", CaseCamel, "",
+			`{"user":{"id":"user-1","email":"case@example.com","custom":{"first_name":"Ada"},"createdAt":"2024-01-02T03:04:05Z","emailVerified":true}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newOfflineManager(t, &Config{ResponseCase: tt.caseName})
+			body, err := json.Marshal(m.authResponse(user, tt.token))
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			if string(body) != tt.want {
+				t.Fatalf("authResponse = %s, want %s", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestBearerPrefix(t *testing.T) {
+	user := &User{ID: "user-1", Email: "bearer@example.com"}
+
+	tests := []struct {
+		name   string
+		prefix bool
+		shape  string
+		token  string
+		want   string
+	}{
+		{"off", false, "", "abc", "abc"},
+		{"on", true, "", "abc", "Bearer abc"},
+		{"on, token only shape", true, ShapeTokenOnly, "abc", "Bearer abc"},
+		// No token, e.g. delivered by cookie only, stays empty
+		{"on, no token", true, "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newOfflineManager(t, &Config{BearerPrefix: tt.prefix, ResponseShape: tt.shape})
+			body, err := json.Marshal(m.authResponse(user, tt.token))
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			var resp struct{ Token string }
+			if err := json.Unmarshal(body, &resp); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			if resp.Token != tt.want {
+				t.Fatalf("token = %q, want %q", resp.Token, tt.want)
+			}
+		})
+	}
+}
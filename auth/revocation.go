@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RevokeToken blacklists a single token by its jti claim until expiresAt,
+// the token's own "exp" (Claims.ExpiresAt, or "tokenExpiresAt" in the Gin
+// context). A zero expiresAt keeps the entry forever.
+func (m *Manager) RevokeToken(jti string, expiresAt time.Time) error {
+	if jti == "" {
+		return errors.New("jti is required")
+	}
+
+	entry := bson.M{
+		"jti":        jti,
+		"revoked_at": time.Now(),
+	}
+	if !expiresAt.IsZero() {
+		// The token is rejected as expired after that, so the TTL index may drop the entry
+		entry["expires_at"] = expiresAt
+	}
+
+	_, err := m.db.InsertOne(m.config.Collections.RevokedTokens, entry)
+	m.forgetEmptyBlacklist()
+	if err != nil {
+		return errors.New("failed to revoke token")
+	}
+
+	return nil
+}
+
+// RevokeAllUserTokens invalidates every token issued to the user so far
+// by bumping the user's token version
+func (m *Manager) RevokeAllUserTokens(userID string) error {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return errors.New("invalid user ID")
+	}
+
+	err = m.db.UpdateOne(
+		m.config.DatabaseName,
+		bson.M{"_id": objID},
+		bson.M{"$inc": bson.M{"token_version": 1}},
+	)
+	m.invalidateUser(userID)
+	if err != nil {
+		return errors.New("failed to revoke tokens")
+	}
+
+	return nil
+}
+
+// blacklistRecheck is how long validation trusts a finding that the
+// blacklist is empty before querying it again. Tokens revoked through
+// another Manager, e.g. on another instance, may be accepted until then.
+const blacklistRecheck = time.Minute
+
+// checkRevocation rejects blacklisted tokens and tokens with a stale version,
+// and returns the user loaded for the version check. That is one query per
+// request, or two while the blacklist has entries; see blacklistRecheck.
+func (m *Manager) checkRevocation(ctx context.Context, userID string, claims jwt.MapClaims) (*User, error) {
+	if jti, ok := claims["jti"].(string); ok {
+		empty, err := m.blacklistEmpty(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !empty {
+			var revoked bson.M
+			err := m.db.FindOneContext(ctx, m.config.Collections.RevokedTokens, bson.M{"jti": jti}, &revoked)
+			if err == nil {
+				return nil, ErrTokenRevoked
+			}
+			if !errors.Is(err, mongo.ErrNoDocuments) {
+				return nil, err
+			}
+		}
+	}
+
+	user, err := m.GetUserByIDContext(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tokens issued before versioning carry no "ver" claim and count as version 0
+	version, _ := claims["ver"].(float64)
+	if int(version) != user.TokenVersion {
+		return nil, ErrTokenRevoked
+	}
+
+	return user, nil
+}
+
+// blacklistEmpty reports whether no token is revoked, checking the database
+// at most once per blacklistRecheck
+func (m *Manager) blacklistEmpty(ctx context.Context) (bool, error) {
+	if m.db == nil {
+		// Only a UserStore; RevokeToken needs MongoDB
+		return true, nil
+	}
+
+	m.blacklistMu.Lock()
+	checkedAt, hasEntries, gen := m.blacklistCheckedAt, m.blacklistHasEntries, m.blacklistGen
+	m.blacklistMu.Unlock()
+	if !checkedAt.IsZero() && time.Since(checkedAt) < blacklistRecheck {
+		return !hasEntries, nil
+	}
+
+	var entry bson.M
+	err := m.db.FindOneContext(ctx, m.config.Collections.RevokedTokens, bson.M{}, &entry)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return false, err
+	}
+	hasEntries = err == nil
+
+	m.blacklistMu.Lock()
+	// A RevokeToken that overlapped the query may not be in its result
+	if m.blacklistGen == gen {
+		m.blacklistCheckedAt, m.blacklistHasEntries = time.Now(), hasEntries
+	}
+	m.blacklistMu.Unlock()
+	return !hasEntries, nil
+}
+
+// forgetEmptyBlacklist makes validation query the blacklist again, after a
+// token was revoked through this Manager
+func (m *Manager) forgetEmptyBlacklist() {
+	m.blacklistMu.Lock()
+	m.blacklistGen++
+	m.blacklistCheckedAt = time.Time{}
+	m.blacklistMu.Unlock()
+}
@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestRevokeTokenOutlivesTokenExpiry(t *testing.T) {
+	m := newTestManager(t, &Config{TokenExpiry: 1})
+	mustSignup(t, m, "revoke@example.com", "correct horse battery")
+
+	tests := []struct {
+		name  string
+		token func() (string, error)
+	}{
+		{"remember me login", func() (string, error) {
+			_, token, err := m.Login(LoginRequest{Email: "revoke@example.com", Password: "correct horse battery", RememberMe: true})
+			return token, err
+		}},
+		{"token with options", func() (string, error) {
+			user, err := m.GetUserByEmail("revoke@example.com")
+			if err != nil {
+				return "", err
+			}
+			return m.GenerateTokenWithOptions(user.ID, TokenOptions{Expiry: 7 * 24 * time.Hour})
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := tt.token()
+			if err != nil {
+				t.Fatalf("issue token: %v", err)
+			}
+			claims, err := m.ParseToken(token)
+			if err != nil {
+				t.Fatalf("ParseToken: %v", err)
+			}
+			if err := m.RevokeToken(claims.TokenID, claims.ExpiresAt); err != nil {
+				t.Fatalf("RevokeToken: %v", err)
+			}
+
+			// Drop every entry the TTL index would have removed once
+			// TokenExpiry has passed
+			cutoff := time.Now().Add(time.Duration(m.config.TokenExpiry)*time.Minute + time.Minute)
+			if err := m.db.DeleteMany(m.config.Collections.RevokedTokens, bson.M{"expires_at": bson.M{"$lt": cutoff}}); err != nil {
+				t.Fatalf("expire entries: %v", err)
+			}
+
+			if _, err := m.ParseToken(token); !errors.Is(err, ErrTokenRevoked) {
+				t.Fatalf("ParseToken after TokenExpiry = %v, want ErrTokenRevoked", err)
+			}
+
+			var entry struct {
+				ExpiresAt time.Time `bson:"expires_at"`
+			}
+			if err := m.db.FindOne(m.config.Collections.RevokedTokens, bson.M{"jti": claims.TokenID}, &entry); err != nil {
+				t.Fatalf("load entry: %v", err)
+			}
+			if !entry.ExpiresAt.Equal(claims.ExpiresAt) {
+				t.Errorf("expires_at = %v, want the token's exp %v", entry.ExpiresAt, claims.ExpiresAt)
+			}
+		})
+	}
+}
+
+func TestRevokeTokenRequiresJTI(t *testing.T) {
+	m := newOfflineManager(t, &Config{})
+	if err := m.RevokeToken("", time.Now()); err == nil {
+		t.Fatal("RevokeToken with empty jti succeeded")
+	}
+}
+
+func TestTokenRevocation(t *testing.T) {
+	m := newTestManager(t, &Config{})
+	user := mustSignup(t, m, "tokens@example.com", "correct horse battery")
+	other := mustSignup(t, m, "other@example.com", "correct horse battery")
+
+	issue := func(userID string) string {
+		token, err := m.GenerateToken(userID)
+		if err != nil {
+			t.Fatalf("GenerateToken: %v", err)
+		}
+		return token
+	}
+	issueCurrent := func(userID string) string {
+		current, err := m.GetUserByID(userID)
+		if err != nil {
+			t.Fatalf("GetUserByID: %v", err)
+		}
+		token, err := m.GenerateTokenWithOptions(userID, TokenOptions{TokenVersion: current.TokenVersion})
+		if err != nil {
+			t.Fatalf("GenerateTokenWithOptions: %v", err)
+		}
+		return token
+	}
+	revoke := func(token string) error {
+		claims, err := m.ParseToken(token)
+		if err != nil {
+			return err
+		}
+		return m.RevokeToken(claims.TokenID, claims.ExpiresAt)
+	}
+
+	first, second, othersToken := issue(user.ID), issue(user.ID), issue(other.ID)
+
+	tests := []struct {
+		name    string
+		action  func() error
+		token   func() string
+		wantErr error
+	}{
+		{"valid", nil, func() string { return first }, nil},
+		{"revoked token", func() error { return revoke(first) }, func() string { return first }, ErrTokenRevoked},
+		{"other token of the same user", nil, func() string { return second }, nil},
+		{"all tokens of the user revoked", func() error { return m.RevokeAllUserTokens(user.ID) }, func() string { return second }, ErrTokenRevoked},
+		{"other user unaffected", nil, func() string { return othersToken }, nil},
+		{"token issued at the current version", nil, func() string { return issueCurrent(user.ID) }, nil},
+		{"token issued at version 0", nil, func() string { return issue(user.ID) }, ErrTokenRevoked},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.action != nil {
+				if err := tt.action(); err != nil {
+					t.Fatalf("revoke: %v", err)
+				}
+			}
+			if _, err := m.ValidateToken(tt.token()); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ValidateToken error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBlacklistSkippedWhileEmpty(t *testing.T) {
+	m := newTestManager(t, &Config{})
+	user := mustSignup(t, m, "blacklist@example.com", "correct horse battery")
+
+	issue := func() (string, *Claims) {
+		token, err := m.GenerateToken(user.ID)
+		if err != nil {
+			t.Fatalf("GenerateToken: %v", err)
+		}
+		claims, err := m.ParseToken(token)
+		if err != nil {
+			t.Fatalf("ParseToken: %v", err)
+		}
+		return token, claims
+	}
+
+	// Revoked by another instance: this Manager found the blacklist empty
+	// and doesn't query it again until blacklistRecheck has passed
+	elsewhere, claims := issue()
+	if _, err := m.db.InsertOne(m.config.Collections.RevokedTokens, bson.M{"jti": claims.TokenID}); err != nil {
+		t.Fatalf("insert entry: %v", err)
+	}
+	if _, err := m.ParseToken(elsewhere); err != nil {
+		t.Fatalf("ParseToken within blacklistRecheck = %v, want the blacklist skipped", err)
+	}
+
+	// Revoking through this Manager makes it query the blacklist again
+	local, claims := issue()
+	if err := m.RevokeToken(claims.TokenID, claims.ExpiresAt); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+	for name, token := range map[string]string{"local": local, "elsewhere": elsewhere} {
+		if _, err := m.ParseToken(token); !errors.Is(err, ErrTokenRevoked) {
+			t.Errorf("ParseToken %s = %v, want ErrTokenRevoked", name, err)
+		}
+	}
+	issue()
+}
+
+func TestSignTokenUniqueJTI(t *testing.T) {
+	m := newOfflineManager(t, &Config{})
+
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		token, err := m.signToken("user-1", 0, time.Hour, time.Time{})
+		if err != nil {
+			t.Fatalf("signToken: %v", err)
+		}
+		claims := jwt.MapClaims{}
+		if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+			t.Fatalf("parse: %v", err)
+		}
+		jti, _ := claims["jti"].(string)
+		if jti == "" || seen[jti] {
+			t.Fatalf("jti %q is empty or repeated", jti)
+		}
+		seen[jti] = true
+	}
+}
@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Password strength scores returned by PasswordStrength
+const (
+	StrengthVeryWeak = iota
+	StrengthWeak
+	StrengthFair
+	StrengthStrong
+	StrengthVeryStrong
+)
+
+// PasswordStrengthResponse is the body of PasswordStrengthHandler
+type PasswordStrengthResponse struct {
+	Score    int      `json:"score"`
+	Feedback []string `json:"feedback"`
+}
+
+// commonPasswords are rejected outright, also with digits appended
+var commonPasswords = map[string]bool{
+	"password": true, "passw0rd": true, "123456": true, "12345678": true,
+	"qwerty": true, "qwertyuiop": true, "abc123": true, "111111": true,
+	"letmein": true, "welcome": true, "monkey": true, "dragon": true,
+	"iloveyou": true, "admin": true, "login": true, "master": true,
+	"sunshine": true, "football": true, "baseball": true, "princess": true,
+	"shadow": true, "superman": true, "trustno1": true, "whatever": true,
+	"starwars": true, "secret": true, "changeme": true, "default": true,
+}
+
+var keyboardRows = []string{"qwertyuiop", "asdfghjkl", "zxcvbnm"}
+
+// wordWithSuffix matches the common "Word123!" shape
+var wordWithSuffix = regexp.MustCompile(`^[A-Za-z][a-z]+[0-9]*[^A-Za-z0-9]?$`)
+
+// PasswordStrength estimates how hard password is to guess and returns a score
+// from StrengthVeryWeak (0) to StrengthVeryStrong (4) with suggestions for
+// improving it. It is a heuristic based on length, character variety and
+// common patterns, meant for strength meters rather than as a policy.
+func (m *Manager) PasswordStrength(password string) (int, []string) {
+	feedback := []string{}
+	runes := []rune(password)
+	if len(runes) == 0 {
+		return StrengthVeryWeak, append(feedback, "Enter a password")
+	}
+
+	lower := strings.ToLower(password)
+	if commonPasswords[lower] || commonPasswords[strings.TrimRight(lower, "0123456789!")] {
+		return StrengthVeryWeak, append(feedback, "This is a commonly used password")
+	}
+
+	var hasLower, hasUpper, hasDigit, hasSymbol, hasOther bool
+	for _, r := range runes {
+		switch {
+		case r >= 'a' && r <= 'z':
+			hasLower = true
+		case r >= 'A' && r <= 'Z':
+			hasUpper = true
+		case r >= '0' && r <= '9':
+			hasDigit = true
+		case r < unicode.MaxASCII:
+			hasSymbol = true
+		default:
+			hasOther = true
+		}
+	}
+
+	pool, classes := 0, 0
+	for _, class := range []struct {
+		present bool
+		size    int
+	}{{hasLower, 26}, {hasUpper, 26}, {hasDigit, 10}, {hasSymbol, 33}, {hasOther, 100}} {
+		if class.present {
+			pool += class.size
+			classes++
+		}
+	}
+
+	repeats, sequences := predictableRunes(runes)
+	keyboard := keyboardRunes(lower)
+	effective := len(runes) - repeats - sequences - keyboard
+	if effective < 1 {
+		effective = 1
+	}
+
+	bits := float64(effective) * math.Log2(float64(pool))
+	if wordWithSuffix.MatchString(password) && (hasUpper || hasDigit || hasSymbol) {
+		// A capitalized word with digits and a symbol tacked on is guessed
+		// like the word alone plus a few bits per suffix character
+		word := strings.TrimRight(password, "0123456789!@#$%^&*?.")
+		letters := len(word) - repeats - sequences - keyboard
+		if letters < 1 {
+			letters = 1
+		}
+		suffix := len(password) - len(word)
+		bits = math.Min(bits, float64(letters)*math.Log2(26)+float64(suffix)*3.3+1)
+		feedback = append(feedback, "A capitalized word with digits or a symbol at the end is easy to guess")
+	}
+
+	if len(runes) < 12 {
+		feedback = append(feedback, "Use at least 12 characters")
+	}
+	if classes < 3 && len(runes) < 16 {
+		feedback = append(feedback, "Mix upper and lower case letters, digits and symbols, or use a longer passphrase")
+	}
+	if repeats > 0 {
+		feedback = append(feedback, `Avoid repeated characters like "aaa"`)
+	}
+	if sequences > 0 {
+		feedback = append(feedback, `Avoid sequences like "abc" or "123"`)
+	}
+	if keyboard > 0 {
+		feedback = append(feedback, `Avoid keyboard patterns like "qwerty"`)
+	}
+
+	switch {
+	case bits < 25:
+		return StrengthVeryWeak, feedback
+	case bits < 35:
+		return StrengthWeak, feedback
+	case bits < 50:
+		return StrengthFair, feedback
+	case bits < 65 || len(runes) < 12:
+		return StrengthStrong, feedback
+	default:
+		return StrengthVeryStrong, feedback
+	}
+}
+
+// predictableRunes counts runes that continue a run of the same character or
+// a +1/-1 sequence started by the two before them
+func predictableRunes(runes []rune) (repeats, sequences int) {
+	for i := 2; i < len(runes); i++ {
+		a, b, c := runes[i-2], runes[i-1], runes[i]
+		switch {
+		case a == b && b == c:
+			repeats++
+		case b-a == c-b && (c-b == 1 || c-b == -1):
+			sequences++
+		}
+	}
+	return repeats, sequences
+}
+
+// keyboardRunes counts runes that continue a run of four or more adjacent keys
+func keyboardRunes(lower string) int {
+	count := 0
+	for _, row := range keyboardRows {
+		for length := len(row); length >= 4; length-- {
+			for start := 0; start+length <= len(row); start++ {
+				if strings.Contains(lower, row[start:start+length]) {
+					count += length - 2
+					lower = strings.Replace(lower, row[start:start+length], " ", 1)
+				}
+			}
+		}
+	}
+	return count
+}
+
+// PasswordStrengthHandler returns Gin handler that scores a password without
+// storing it, for strength meters on signup and change-password forms
+func (m *Manager) PasswordStrengthHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req PasswordStrengthRequest
+		if !m.bindBody(c, &req) {
+			return
+		}
+
+		score, feedback := m.PasswordStrength(req.Password)
+		RespondSuccess(c, 200, PasswordStrengthResponse{Score: score, Feedback: feedback})
+	}
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPasswordStrength(t *testing.T) {
+	m := newOfflineManager(t, &Config{})
+
+	tests := []struct {
+		password     string
+		want         int
+		wantFeedback string // a suggestion that must be included, if set
+	}{
+		{"", StrengthVeryWeak, "Enter a password"},
+		{"password", StrengthVeryWeak, "commonly used"},
+		{"Password1!", StrengthVeryWeak, "commonly used"},
+		{"qwertyuiop123", StrengthVeryWeak, "commonly used"},
+		{"aaaaaaaa", StrengthVeryWeak, "repeated characters"},
+		{"abcdefgh", StrengthVeryWeak, "sequences"},
+		{"hunter2", StrengthWeak, "at least 12 characters"},
+		{"Summer2024!", StrengthFair, "capitalized word"},
+		{"Tr0ub4dor&3", StrengthStrong, "at least 12 characters"},
+		{"correct horse battery staple", StrengthVeryStrong, ""},
+		{"x7#Kp2!qLm9$vR4z", StrengthVeryStrong, ""},
+		{"ñandú-über-straße", StrengthVeryStrong, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			score, feedback := m.PasswordStrength(tt.password)
+			if score != tt.want {
+				t.Fatalf("score = %d, want %d (feedback %v)", score, tt.want, feedback)
+			}
+			if feedback == nil {
+				t.Fatal("feedback is nil, want an empty list")
+			}
+			if tt.wantFeedback == "" && tt.want == StrengthVeryStrong && len(feedback) != 0 {
+				t.Fatalf("feedback = %v for a very strong password", feedback)
+			}
+			if tt.wantFeedback != "" && !strings.Contains(strings.Join(feedback, "\n"), tt.wantFeedback) {
+				t.Fatalf("feedback = %v, want a mention of %q", feedback, tt.wantFeedback)
+			}
+		})
+	}
+}
+
+func TestPasswordStrengthHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := newOfflineManager(t, &Config{})
+	router := gin.New()
+	router.POST("/strength", m.PasswordStrengthHandler())
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantScore  int
+	}{
+		{"weak", `{"password":"password"}`, 200, StrengthVeryWeak},
+		{"strong", `{"password":"correct horse battery staple"}`, 200, StrengthVeryStrong},
+		{"malformed", `{`, 400, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/strength", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != 200 {
+				return
+			}
+			var resp PasswordStrengthResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode %s: %v", w.Body.String(), err)
+			}
+			if resp.Score != tt.wantScore || resp.Feedback == nil {
+				t.Fatalf("response = %+v, want score %d", resp, tt.wantScore)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+//go:build authtest
+
+package auth
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// testTokensEnabled lets validateToken accept TestToken tokens. It is only
+// true in builds with the authtest tag.
+const testTokensEnabled = true
+
+// TestToken returns a token for userID that passes Middleware and ParseToken
+// without the user existing in the database, for tests of protected routes.
+// It is only compiled with the authtest build tag (go test -tags authtest),
+// and builds without the tag treat such tokens like any other.
+func (m *Manager) TestToken(userID string) string {
+	jti, err := generateRandomToken(16)
+	if err != nil {
+		panic("auth: TestToken: " + err.Error())
+	}
+
+	token, err := m.signClaims(jwt.MapClaims{
+		"user_id": userID,
+		"jti":     jti,
+		"test":    true,
+		"exp":     time.Now().Add(time.Duration(m.config.TokenExpiry) * time.Minute).Unix(),
+		"iat":     time.Now().Unix(),
+	})
+	if err != nil {
+		panic("auth: TestToken: " + err.Error())
+	}
+	return token
+}
@@ -0,0 +1,7 @@
+//go:build !authtest
+
+package auth
+
+// testTokensEnabled is false outside authtest builds, so tokens marked as test
+// tokens still go through the revocation and user checks
+const testTokensEnabled = false
@@ -0,0 +1,49 @@
+//go:build !authtest
+
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestTestTokenClaimIgnored(t *testing.T) {
+	m := newTestManager(t, &Config{})
+	user := mustSignup(t, m, "real@example.com", "correct horse battery")
+
+	// The claims TestToken signs, which only authtest builds trust
+	sign := func(userID string) string {
+		token, err := m.signClaims(jwt.MapClaims{
+			"user_id": userID,
+			"jti":     "test-jti",
+			"test":    true,
+			"exp":     time.Now().Add(time.Hour).Unix(),
+			"iat":     time.Now().Unix(),
+		})
+		if err != nil {
+			t.Fatalf("signClaims: %v", err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name    string
+		userID  string
+		wantErr error
+	}{
+		{"existing user", user.ID, nil},
+		{"unknown user", "000000000000000000000000", ErrUserNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := m.ParseToken(sign(tt.userID))
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Fatalf("ParseToken error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+//go:build authtest
+
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// No database: the token must pass without the revocation and user checks
+	m := newOfflineManager(t, &Config{})
+	other := newOfflineManager(t, &Config{Secret: "other-secret"})
+
+	router := gin.New()
+	router.GET("/me", m.Middleware(), func(c *gin.Context) {
+		userID, _ := UserIDFromContext(c)
+		c.String(http.StatusOK, userID)
+	})
+
+	token := m.TestToken("user-1")
+	tests := []struct {
+		name       string
+		token      string
+		wantStatus int
+		wantBody   string
+	}{
+		{"test token", token, http.StatusOK, "user-1"},
+		{"other user", m.TestToken("user-2"), http.StatusOK, "user-2"},
+		{"tampered", token + "x", http.StatusUnauthorized, ""},
+		{"other secret", other.TestToken("user-1"), http.StatusUnauthorized, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/me", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Fatalf("body = %q, want %q", w.Body, tt.wantBody)
+			}
+
+			claims, err := m.ParseToken(tt.token)
+			if (err == nil) != (tt.wantStatus == http.StatusOK) {
+				t.Fatalf("ParseToken error = %v", err)
+			}
+			if err == nil && claims.UserID != tt.wantBody {
+				t.Fatalf("ParseToken user ID = %q, want %q", claims.UserID, tt.wantBody)
+			}
+		})
+	}
+}
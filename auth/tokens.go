@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/berkkaradalan/CoreGo/database"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// oneTimeToken is a stored single-use token; only its hash is persisted
+type oneTimeToken struct {
+	UserID    string    `bson:"user_id"`
+	TokenHash string    `bson:"token_hash"`
+	CreatedAt time.Time `bson:"created_at"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// issueOneTimeToken generates a random token for the user and stores its hash
+func (m *Manager) issueOneTimeToken(ctx context.Context, collection, userID string, ttl time.Duration) (string, error) {
+	token, err := generateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = m.db.InsertOneContext(ctx, collection, oneTimeToken{
+		UserID:    userID,
+		TokenHash: HashToken(token),
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return "", errors.New("failed to store token")
+	}
+
+	return token, nil
+}
+
+// consumeOneTimeToken deletes the unexpired token and returns its user ID.
+// Finding and deleting is one operation, so concurrent uses of the same token
+// can't both succeed.
+func (m *Manager) consumeOneTimeToken(collection, token string) (string, error) {
+	if token == "" {
+		return "", ErrInvalidToken
+	}
+
+	filter := bson.M{
+		"token_hash": HashToken(token),
+		"expires_at": bson.M{"$gt": time.Now()},
+	}
+
+	record, err := m.db.FindOneAndDelete(collection, filter, nil)
+	if errors.Is(err, database.ErrNotFound) {
+		return "", ErrInvalidToken
+	}
+	if err != nil {
+		return "", err
+	}
+
+	userID, _ := record["user_id"].(string)
+	return userID, nil
+}
+
+// HashToken returns the hex SHA-256 of a random token, for storing API keys,
+// invites and similar secrets without keeping the token itself. Only use it for
+// high-entropy tokens; passwords need HashPassword.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CompareTokenHash reports whether token hashes to hash, in constant time
+func CompareTokenHash(hash, token string) bool {
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(HashToken(token))) == 1
+}
+
+// buildLink appends the token to base as a query parameter, or returns the bare token
+func buildLink(base, token string) string {
+	if base == "" {
+		return token
+	}
+	sep := "?"
+	if strings.Contains(base, "?") {
+		sep = "&"
+	}
+	return base + sep + "token=" + url.QueryEscape(token)
+}
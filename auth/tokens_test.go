@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConsumeOneTimeToken(t *testing.T) {
+	m := newTestManager(t, &Config{})
+	collection := m.config.Collections.PasswordResets
+
+	tests := []struct {
+		name    string
+		ttl     time.Duration
+		token   func(issued string) string
+		wantErr error
+	}{
+		{"valid", time.Hour, func(issued string) string { return issued }, nil},
+		{"expired", -time.Minute, func(issued string) string { return issued }, ErrInvalidToken},
+		{"unknown", time.Hour, func(string) string { return "not-a-token" }, ErrInvalidToken},
+		{"empty", time.Hour, func(string) string { return "" }, ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issued, err := m.issueOneTimeToken(t.Context(), collection, "user-1", tt.ttl)
+			if err != nil {
+				t.Fatalf("issueOneTimeToken: %v", err)
+			}
+
+			userID, err := m.consumeOneTimeToken(collection, tt.token(issued))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("consumeOneTimeToken error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && userID != "user-1" {
+				t.Fatalf("user ID = %q, want user-1", userID)
+			}
+		})
+	}
+}
+
+func TestConsumeOneTimeTokenOnce(t *testing.T) {
+	m := newTestManager(t, &Config{})
+	collection := m.config.Collections.EmailVerifications
+
+	token, err := m.issueOneTimeToken(t.Context(), collection, "user-1", time.Hour)
+	if err != nil {
+		t.Fatalf("issueOneTimeToken: %v", err)
+	}
+
+	const attempts = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, attempts)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := m.consumeOneTimeToken(collection, token)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, ErrInvalidToken):
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("%d concurrent uses succeeded, want 1", succeeded)
+	}
+}
+
+func TestHashToken(t *testing.T) {
+	const token = "4f1c2a"
+	hash := HashToken(token)
+
+	tests := []struct {
+		name  string
+		hash  string
+		token string
+		want  bool
+	}{
+		{"matching", hash, token, true},
+		{"other token", hash, "4f1c2b", false},
+		{"empty token", hash, "", false},
+		{"uppercase hash", strings.ToUpper(hash), token, false},
+		{"truncated hash", hash[:32], token, false},
+		{"known value", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CompareTokenHash(tt.hash, tt.token); got != tt.want {
+				t.Fatalf("CompareTokenHash(%q, %q) = %v, want %v", tt.hash, tt.token, got, tt.want)
+			}
+		})
+	}
+
+	if HashToken(token) != hash || len(hash) != 64 {
+		t.Fatalf("HashToken = %q, want a stable 64 character hex digest", hash)
+	}
+}
@@ -1,47 +1,285 @@
 package auth
 
-import "time"
+import (
+    "time"
+
+    "github.com/gin-gonic/gin"
+)
 
 type Config struct {
-    Secret         string
-    TokenExpiry    int
-    DatabaseName   string
+    Secret           string
+    TokenExpiry      int
+    RememberMeExpiry int           // Optional: token lifetime in minutes for RememberMe logins (default: 30 days)
+    DatabaseName     string
+    Issuer           string        // Optional: set as "iss" and required on validation
+    Audience         string        // Optional: set as "aud" and required on validation
+    ClockSkew        time.Duration // Optional: leeway applied to exp/nbf/iat checks
+    MaxBodyBytes     int64         // Optional: request body limit for auth handlers (default: 1MB)
+    AllowFormBodies  bool          // Optional: auth handlers also accept form bodies, not only JSON
+
+    // Optional: validates User.Custom on signup and profile update
+    CustomValidator func(custom map[string]any) error
+
+    // Optional: indexes ensured on the users collection at startup
+    Indexes []IndexSpec
+
+    // Optional: unique index on email, EmailIndexUnique or EmailIndexCaseInsensitive.
+    // The case-insensitive index also makes email lookups ignore case, so
+    // "A@b.com" and "a@b.com" are the same account. Default: no index.
+    EmailIndex string
+
+    // Optional: delivers reset and verification emails (default: NoopMailer)
+    Mailer                  Mailer
+    ResetPasswordURL        string        // Link base for reset emails, the token is appended as ?token=
+    VerifyEmailURL          string        // Link base for verification emails
+    ResetTokenExpiry        time.Duration // default: 1 hour
+    VerificationTokenExpiry time.Duration // default: 24 hours
+    ResetRateLimit          int           // reset requests per email and per IP in ResetRateWindow (default: 5, -1 disables)
+    ResetRateWindow         time.Duration // default: 1 hour
+
+    // Optional: proxies, as IPs or CIDR ranges, whose ClientIPHeader is trusted
+    // for the client address used by rate limiting and the audit log. Without
+    // any, the peer address is used and forwarding headers are ignored.
+    TrustedProxies []string
+    ClientIPHeader string // HeaderForwardedFor (default) or HeaderRealIP
+
+    // Optional: brute-force protection for logins, both disabled when 0.
+    // MaxFailedLogins locks an email after that many failures for LockoutDuration;
+    // LoginRateLimit throttles an IP after that many failures, across all emails,
+    // for LoginRateWindow. Counters are kept in memory per process.
+    MaxFailedLogins int
+    LockoutDuration time.Duration // default: 15 minutes
+    LoginRateLimit  int
+    LoginRateWindow time.Duration // default: 15 minutes
+
+    // Optional: SignupHandler responds 403, e.g. for invite-only apps.
+    // Signup can still be called directly.
+    DisableSignup bool
+
+    // Optional: Signup requires an unexpired invite from CreateInvite for the
+    // same email. The invite is consumed on success.
+    RequireInvite bool
+
+    // Optional: email domains Signup accepts or rejects, e.g. "mycompany.com".
+    // A domain also covers its subdomains. Blocked domains win; an empty
+    // allowlist accepts every domain that isn't blocked.
+    AllowedEmailDomains []string
+    BlockedEmailDomains []string
+
+    // Optional: issue a token on signup (default: true). Set to auth.Bool(false)
+    // to require a separate login, e.g. after email verification.
+    AutoLoginAfterSignup *bool
+
+    // Optional: write the 401 and 403 responses of Middleware, RequireRole and
+    // the handlers, e.g. to redirect to a login page. They must write a
+    // response; the request is aborted afterwards. Default: RespondError.
+    OnUnauthorized func(c *gin.Context, code, message string)
+    OnForbidden    func(c *gin.Context, message string)
+
+    // Optional: caches GetUserByID results, including the lookups behind token
+    // validation, e.g. NewMemoryUserCache(10000, 30*time.Second). Changes made
+    // through the Manager invalidate the user; with a per-process cache, other
+    // instances may serve the old user, and accept revoked tokens, until the TTL.
+    UserCache UserCache
+
+    // Optional: keeps users somewhere other than the MongoDB users collection,
+    // e.g. NewPostgresUserStore. Signup, login, GetUserByID, GetUserByEmail and
+    // token validation use it, so New may get no database. Everything else,
+    // such as profile updates, password changes, verification, invites and
+    // revocation, still needs MongoDB.
+    UserStore UserStore
+
+    // Optional: receives errors that can't be returned to the caller, e.g. a
+    // failed audit write or background email (default: log.Printf)
+    OnError func(err error)
+
+    // Optional: counters for signups, logins and token validations (default: NoopMetrics)
+    Metrics Metrics
+
+    // Optional: records password changes, resets and account deletions
+    // (default: MongoAuditLogger writing to Collections.AuditLog, or
+    // NoopAuditLogger when New gets no database)
+    AuditLogger AuditLogger
+
+    // Optional: top-level signup body fields captured into Custom; others are dropped
+    SignupCustomFields []string
+
+    // Optional: initial Custom values for new users; keys sent in the signup
+    // request win. Merged at the top level only.
+    DefaultCustom map[string]any
+
+    // Optional: collection names for auth artifacts, empty fields use the defaults
+    Collections Collections
+
+    // Optional: JSON key casing of handler responses, CaseSnake (default) or CaseCamel
+    ResponseCase string
+
+    // Optional: body of signup and login responses, ShapeUserAndToken (default),
+    // ShapeTokenOnly or ShapeUserOnly. ShapeUserOnly requires a cookie TokenDelivery.
+    ResponseShape string
+
+    // Optional: how signup, login and change-password hand out tokens:
+    // DeliverBody (default), DeliverCookie or DeliverBoth. With a cookie,
+    // Middleware also accepts the token from it when no Authorization header is sent.
+    TokenDelivery string
+    Cookie        CookieConfig
+
+    // Optional: prefix tokens in handler responses with "Bearer ",
+    // so clients can send the token field as the Authorization header as is
+    BearerPrefix bool
+
+    // Optional: User.Role value allowed to perform admin actions (default: "admin")
+    AdminRole string
+
+    // Optional: number of previous password hashes that ChangePassword refuses
+    // to reuse. 0 only blocks the current password.
+    PasswordHistorySize int
+
+    // Optional: ChangePassword rejects requests without ConfirmPassword.
+    // Otherwise it is only checked when sent.
+    RequirePasswordConfirmation bool
+
+    // Optional: key rotation. New tokens are signed with Secret and carry KeyID
+    // in the "kid" header; tokens whose kid is in PreviousKeys still validate
+    // against that secret. The "" entry covers tokens issued before KeyID was set.
+    KeyID        string
+    PreviousKeys map[string]string
+
+    // Optional: create TTL and configured indexes when the manager starts
+    // (default: true, skipped when New gets no database). On large production collections set it to
+    // auth.Bool(false) and run Manager.EnsureIndexes from a deploy step.
+    EnsureIndexes *bool
+}
+
+// Response key casing options for Config.ResponseCase
+const (
+    CaseSnake = "snake"
+    CaseCamel = "camel"
+)
+
+// Signup and login response shapes for Config.ResponseShape
+const (
+    ShapeUserAndToken = "user_and_token"
+    ShapeTokenOnly    = "token_only"
+    ShapeUserOnly     = "user_only"
+)
+
+// Unique email index options for Config.EmailIndex
+const (
+    EmailIndexUnique          = "unique"
+    EmailIndexCaseInsensitive = "case_insensitive"
+)
+
+// Collections names the collections used for auth artifacts
+type Collections struct {
+    RevokedTokens      string // default: "revoked_tokens"
+    PasswordResets     string // default: "password_resets"
+    EmailVerifications string // default: "email_verifications"
+    ResetRequests      string // default: "password_reset_requests", audit trail of reset requests
+    AuditLog           string // default: "audit_log", used by the default AuditLogger
+    Invites            string // default: "invites"
+}
+
+// IndexSpec declares an index on the users collection.
+// Fields are dotted paths such as "custom.phone"; prefix with "-" for descending.
+type IndexSpec struct {
+    Fields []string
+    Unique bool
+    Name   string
 }
 
 type User struct {
-    ID        string                 `bson:"_id,omitempty" json:"id"`
-    Email     string                 `bson:"email" json:"email"`
-    Password  string                 `bson:"password" json:"-"`
-    Custom    map[string]interface{} `bson:"custom,omitempty" json:"custom,omitempty"`
-    CreatedAt time.Time              `bson:"created_at" json:"created_at"`
+    ID            string                 `bson:"_id,omitempty" json:"id"`
+    Email         string                 `bson:"email" json:"email"`
+    Password      string                 `bson:"password" json:"-"`
+    Custom        map[string]interface{} `bson:"custom,omitempty" json:"custom,omitempty"`
+    CreatedAt     time.Time              `bson:"created_at" json:"created_at"`
+    EmailVerified bool                   `bson:"email_verified" json:"email_verified"`
+    Role          string                 `bson:"role,omitempty" json:"role,omitempty"`
+    DeletedAt     *time.Time             `bson:"deleted_at,omitempty" json:"-"`
+
+    // PasswordHistory holds previous hashes, newest first, up to PasswordHistorySize
+    PasswordHistory []string             `bson:"password_history,omitempty" json:"-"`
+
+    // TokenVersion is embedded in issued tokens; bumping it revokes them all
+    TokenVersion  int                    `bson:"token_version" json:"-"`
+}
+
+// PublicUser is a User without any credential fields
+type PublicUser struct {
+    ID            string                 `bson:"_id,omitempty" json:"id"`
+    Email         string                 `bson:"email" json:"email"`
+    Custom        map[string]interface{} `bson:"custom,omitempty" json:"custom,omitempty"`
+    CreatedAt     time.Time              `bson:"created_at" json:"created_at"`
+    EmailVerified bool                   `bson:"email_verified" json:"email_verified"`
+    Role          string                 `bson:"role,omitempty" json:"role,omitempty"`
+}
+
+// reservedSignupFields are request or account fields that SignupCustomFields
+// can't capture, so a signup body can never appear to set them
+var reservedSignupFields = map[string]bool{
+    "email": true, "password": true, "custom": true, "invite_token": true,
+    "id": true, "_id": true, "role": true, "email_verified": true, "verified": true,
+    "created_at": true, "deleted_at": true, "deleted_email": true, "token_version": true, "password_history": true,
 }
 
-// SignupRequest
+// SignupRequest holds everything a signup body can set. Account fields such as
+// Role, EmailVerified and TokenVersion are never bound from requests; Signup
+// always starts them at their zero values. Never bind request bodies into User.
 type SignupRequest struct {
-    Email    string
-    Password string
-    Custom   map[string]interface{}
+    Email       string                 `form:"email"`
+    Password    string                 `form:"password"`
+    Custom      map[string]interface{} `form:"custom"` // In form bodies, a JSON object string
+    InviteToken string                 `json:"invite_token" form:"invite_token"` // Required when Config.RequireInvite is set
 }
 
 // LoginRequest
 type LoginRequest struct {
-    Email    string
-    Password string
+    Email      string `form:"email"`
+    Password   string `form:"password"`
+    RememberMe bool   `json:"remember_me" form:"remember_me"` // Issue a token valid for Config.RememberMeExpiry
 }
 
 // AuthResponse
 type AuthResponse struct {
     User  User   `json:"user"`
-    Token string `json:"token"`
+    Token string `json:"token,omitempty"`
 }
 
 // UpdateProfileRequest
 type UpdateProfileRequest struct {
-    Custom map[string]interface{} `json:"custom"`
+    Custom map[string]interface{} `json:"custom" form:"custom"`
+
+    // Merge applies Custom as a JSON merge patch: only the given keys change,
+    // nested objects are merged and null values delete the key. Without it
+    // Custom replaces the stored object.
+    Merge bool `json:"merge" form:"merge"`
 }
 
 // ChangePasswordRequest
 type ChangePasswordRequest struct {
-    OldPassword string `json:"old_password"`
-    NewPassword string `json:"new_password"`
-}
\ No newline at end of file
+    OldPassword     string `json:"old_password" form:"old_password"`
+    NewPassword     string `json:"new_password" form:"new_password"`
+    ConfirmPassword string `json:"confirm_password" form:"confirm_password"` // Must equal NewPassword when set or with RequirePasswordConfirmation
+}
+
+// ForgotPasswordRequest
+type ForgotPasswordRequest struct {
+    Email string `json:"email" form:"email"`
+}
+
+// ResetPasswordRequest
+type ResetPasswordRequest struct {
+    Token       string `json:"token" form:"token"`
+    NewPassword string `json:"new_password" form:"new_password"`
+}
+
+// PasswordStrengthRequest
+type PasswordStrengthRequest struct {
+    Password string `json:"password" form:"password"`
+}
+
+// VerifyEmailRequest
+type VerifyEmailRequest struct {
+    Token string `json:"token" form:"token"`
+}
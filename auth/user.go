@@ -1,36 +1,204 @@
 package auth
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
+	"github.com/berkkaradalan/CoreGo/database"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
-// GetUserByID finds a user by ID
+// notDeleted filters soft-deleted accounts out of user lookups
+var notDeleted = bson.M{"$exists": false}
+
+// GetUserByID finds a user by ID, returning ErrUserNotFound for soft-deleted accounts
 func (m *Manager) GetUserByID(userID string) (*User, error) {
+	return m.GetUserByIDContext(context.Background(), userID)
+}
+
+// GetUserByIDContext is like GetUserByID but runs under ctx
+func (m *Manager) GetUserByIDContext(ctx context.Context, userID string) (*User, error) {
+	if m.config.UserCache != nil {
+		if cached, ok := m.config.UserCache.Get(userID); ok {
+			return cached, nil
+		}
+	}
+	gen := m.cacheGeneration()
+
+	user, err := m.loadUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	m.cacheUser(gen, user)
+	return user, nil
+}
+
+// loadUser reads the user from the UserStore or the users collection
+func (m *Manager) loadUser(ctx context.Context, userID string) (*User, error) {
+	if m.config.UserStore != nil {
+		return m.config.UserStore.GetUserByID(userID)
+	}
+
 	objID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
 		return nil, errors.New("invalid user ID")
 	}
 
 	var user User
-	err = m.db.FindOne(m.config.DatabaseName, bson.M{"_id": objID}, &user)
+	err = m.db.FindOneContext(ctx, m.config.DatabaseName, bson.M{"_id": objID, "deleted_at": notDeleted}, &user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrLookupFailed, err)
+	}
+
+	user.ID = userID
+	return &user, nil
+}
+
+// Public returns the user without credential fields
+func (u *User) Public() *PublicUser {
+	return &PublicUser{
+		ID:            u.ID,
+		Email:         u.Email,
+		Custom:        u.Custom,
+		CreatedAt:     u.CreatedAt,
+		EmailVerified: u.EmailVerified,
+		Role:          u.Role,
+	}
+}
+
+// GetUserPublic finds a user by ID without loading the password hash.
+// Soft-deleted accounts are not found.
+func (m *Manager) GetUserPublic(userID string) (*PublicUser, error) {
+	return m.GetUserPublicContext(context.Background(), userID)
+}
+
+// GetUserPublicContext is like GetUserPublic but runs under ctx
+func (m *Manager) GetUserPublicContext(ctx context.Context, userID string) (*PublicUser, error) {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	var user PublicUser
+	err = m.db.FindOneContext(ctx, m.config.DatabaseName, bson.M{"_id": objID, "deleted_at": notDeleted}, &user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrUserNotFound
+	}
 	if err != nil {
-		return nil, errors.New("user not found")
+		return nil, fmt.Errorf("%w: %w", ErrLookupFailed, err)
 	}
 
 	user.ID = userID
 	return &user, nil
 }
 
+// GetUsersByIDs loads several users with a single query. Users are returned
+// in the order of ids with passwords stripped; unknown IDs and soft-deleted
+// accounts are skipped.
+// Malformed IDs are skipped too and reported through an *InvalidIDsError
+// alongside the users that were found.
+func (m *Manager) GetUsersByIDs(ids []string) ([]User, error) {
+	objIDs := make([]primitive.ObjectID, 0, len(ids))
+	var invalid []string
+	for _, id := range ids {
+		objID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			invalid = append(invalid, id)
+			continue
+		}
+		objIDs = append(objIDs, objID)
+	}
+
+	var found []map[string]any
+	if len(objIDs) > 0 {
+		var err error
+		found, err = m.db.FindContext(context.Background(), m.config.DatabaseName, bson.M{"_id": bson.M{"$in": objIDs}, "deleted_at": notDeleted})
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrLookupFailed, err)
+		}
+	}
+
+	byID := make(map[string]User, len(found))
+	for _, doc := range found {
+		user, err := decodeUser(doc)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrLookupFailed, err)
+		}
+		user.Password = ""
+		byID[user.ID] = user
+	}
+
+	users := make([]User, 0, len(found))
+	for _, id := range ids {
+		if user, ok := byID[id]; ok {
+			users = append(users, user)
+			delete(byID, id) // duplicate IDs return the user once
+		}
+	}
+
+	if len(invalid) > 0 {
+		return users, &InvalidIDsError{IDs: invalid}
+	}
+	return users, nil
+}
+
+// decodeUser converts a document returned by the database wrapper into a User
+func decodeUser(doc map[string]any) (User, error) {
+	var user User
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return user, err
+	}
+	err = bson.Unmarshal(raw, &user)
+	return user, err
+}
+
+// ListUsers returns one page of users, newest first, without soft-deleted
+// accounts or password hashes
+func (m *Manager) ListUsers(page, pageSize int) (*database.Page[PublicUser], error) {
+	users, err := database.FindPage[PublicUser](
+		m.db,
+		m.config.DatabaseName,
+		bson.M{"deleted_at": notDeleted},
+		bson.D{{Key: "created_at", Value: -1}},
+		page,
+		pageSize,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrLookupFailed, err)
+	}
+	return users, nil
+}
+
 // UpdateProfile updates user's custom fields
 func (m *Manager) UpdateProfile(userID string, req UpdateProfileRequest) (*User, error) {
+	return m.UpdateProfileContext(context.Background(), userID, req)
+}
+
+// UpdateProfileContext is like UpdateProfile but runs under ctx
+func (m *Manager) UpdateProfileContext(ctx context.Context, userID string, req UpdateProfileRequest) (*User, error) {
 	objID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
 		return nil, errors.New("invalid user ID")
 	}
 
+	if req.Merge {
+		return m.mergeProfile(ctx, userID, objID, req.Custom)
+	}
+
+	if err := m.validateCustom(req.Custom); err != nil {
+		return nil, err
+	}
+
 	// Update custom fields
 	update := bson.M{
 		"$set": bson.M{
@@ -38,53 +206,245 @@ func (m *Manager) UpdateProfile(userID string, req UpdateProfileRequest) (*User,
 		},
 	}
 
-	err = m.db.UpdateOne(m.config.DatabaseName, bson.M{"_id": objID}, update)
+	err = m.db.UpdateOneContext(ctx, m.config.DatabaseName, bson.M{"_id": objID}, update)
+	m.invalidateUser(userID)
 	if err != nil {
 		return nil, errors.New("failed to update profile")
 	}
 
 	// Return updated user
-	return m.GetUserByID(userID)
+	return m.GetUserByIDContext(ctx, userID)
+}
+
+// mergeProfile applies patch to the user's custom data with $set and $unset
+// on dotted paths, so keys not in the patch are left alone
+func (m *Manager) mergeProfile(ctx context.Context, userID string, objID primitive.ObjectID, patch map[string]any) (*User, error) {
+	set, unset := bson.M{}, bson.M{}
+	if err := flattenPatch("custom", patch, set, unset); err != nil {
+		return nil, err
+	}
+	if len(set) == 0 && len(unset) == 0 {
+		return m.GetUserByIDContext(ctx, userID)
+	}
+
+	// The validator sees the custom data as it will be after the update
+	if m.config.CustomValidator != nil {
+		user, err := m.GetUserByIDContext(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if err := m.validateCustom(applyPatch(user.Custom, patch)); err != nil {
+			return nil, err
+		}
+	}
+
+	update := bson.M{}
+	if len(set) > 0 {
+		// Dotted paths can't be set inside a null custom, so replace it first
+		err := m.db.UpdateOneContext(ctx, m.config.DatabaseName,
+			bson.M{"_id": objID, "custom": bson.M{"$type": "null"}},
+			bson.M{"$set": bson.M{"custom": bson.M{}}})
+		if err != nil {
+			return nil, errors.New("failed to update profile")
+		}
+		update["$set"] = set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	err := m.db.UpdateOneContext(ctx, m.config.DatabaseName, bson.M{"_id": objID}, update)
+	m.invalidateUser(userID)
+	if err != nil {
+		return nil, errors.New("failed to update profile")
+	}
+
+	return m.GetUserByIDContext(ctx, userID)
+}
+
+// flattenPatch turns a merge patch into dotted $set and $unset paths under prefix
+func flattenPatch(prefix string, patch map[string]any, set, unset bson.M) error {
+	for key, value := range patch {
+		if key == "" || strings.HasPrefix(key, "$") || strings.Contains(key, ".") {
+			return fmt.Errorf("invalid custom key %q", key)
+		}
+		path := prefix + "." + key
+
+		switch v := value.(type) {
+		case nil:
+			unset[path] = ""
+		case map[string]any:
+			// An empty object changes nothing; setting it would wipe the stored value
+			if len(v) == 0 {
+				continue
+			}
+			if err := flattenPatch(path, v, set, unset); err != nil {
+				return err
+			}
+		default:
+			set[path] = v
+		}
+	}
+	return nil
+}
+
+// applyPatch returns a copy of doc with the merge patch applied
+func applyPatch(doc, patch map[string]any) map[string]any {
+	result := make(map[string]any, len(doc)+len(patch))
+	for k, v := range doc {
+		result[k] = v
+	}
+	for k, v := range patch {
+		switch pv := v.(type) {
+		case nil:
+			delete(result, k)
+		case map[string]any:
+			existing, ok := result[k].(map[string]any)
+			if m, isM := result[k].(primitive.M); !ok && isM {
+				existing = m
+			}
+			// Like the $set paths, a patch that adds nothing doesn't create the object
+			if merged := applyPatch(existing, pv); existing != nil || len(merged) > 0 {
+				result[k] = merged
+			}
+		default:
+			result[k] = v
+		}
+	}
+	return result
 }
 
 // ChangePassword changes user password
 func (m *Manager) ChangePassword(userID string, req ChangePasswordRequest) error {
-	// 1. Get user
-	user, err := m.GetUserByID(userID)
+	return m.ChangePasswordContext(context.Background(), userID, req)
+}
+
+// ChangePasswordContext is like ChangePassword but runs under ctx
+func (m *Manager) ChangePasswordContext(ctx context.Context, userID string, req ChangePasswordRequest) error {
+	_, err := m.changePassword(ctx, userID, req)
+	return err
+}
+
+// changePassword changes the password and returns the user as loaded before
+// the change, whose tokens are now at version user.TokenVersion+1
+func (m *Manager) changePassword(ctx context.Context, userID string, req ChangePasswordRequest) (*User, error) {
+	// 1. Validate the new password and its confirmation
+	if err := validatePassword(req.NewPassword); err != nil {
+		return nil, err
+	}
+	if req.ConfirmPassword != req.NewPassword && (req.ConfirmPassword != "" || m.config.RequirePasswordConfirmation) {
+		return nil, ErrPasswordMismatch
+	}
+
+	// 2. Get user
+	user, err := m.GetUserByIDContext(ctx, userID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// 2. Verify old password
+	// 3. Verify old password
 	if !VerifyPassword(user.Password, req.OldPassword) {
-		return errors.New("invalid old password")
+		return nil, errors.New("invalid old password")
 	}
 
-	// 3. Hash new password
+	// 4. Check reuse
+	if VerifyPassword(user.Password, req.NewPassword) {
+		return nil, ErrPasswordReused
+	}
+	for _, previous := range user.PasswordHistory {
+		if VerifyPassword(previous, req.NewPassword) {
+			return nil, ErrPasswordReused
+		}
+	}
+
+	// 5. Hash new password
 	hashedPassword, err := HashPassword(req.NewPassword)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// 6. Update password, push the old hash into the history and
+	// invalidate every previously issued token
+	set := bson.M{"password": hashedPassword}
+	if m.config.PasswordHistorySize > 0 {
+		history := append([]string{user.Password}, user.PasswordHistory...)
+		if len(history) > m.config.PasswordHistorySize {
+			history = history[:m.config.PasswordHistorySize]
+		}
+		set["password_history"] = history
 	}
 
-	// 4. Update password
 	objID, _ := primitive.ObjectIDFromHex(userID)
-	err = m.db.UpdateOne(
+	err = m.db.UpdateOneContext(ctx, 
 		m.config.DatabaseName,
 		bson.M{"_id": objID},
-		bson.M{"$set": bson.M{"password": hashedPassword}},
+		bson.M{
+			"$set": set,
+			"$inc": bson.M{"token_version": 1},
+		},
 	)
+	m.invalidateUser(userID)
+	if err != nil {
+		return nil, err
+	}
 
-	return err
+	return user, nil
+}
+
+// IsAdmin reports whether the user has the configured admin role
+func (m *Manager) IsAdmin(user *User) bool {
+	return user.Role != "" && user.Role == m.config.AdminRole
 }
 
-// DeleteAccount deletes user account
+// SoftDeleteAccount marks the account as deleted and revokes its tokens.
+// The document is kept; soft-deleted users can no longer log in. The email
+// moves to deleted_email, so it can sign up again under the unique email index.
+func (m *Manager) SoftDeleteAccount(userID string) error {
+	return m.SoftDeleteAccountContext(context.Background(), userID)
+}
+
+// SoftDeleteAccountContext is like SoftDeleteAccount but runs under ctx
+func (m *Manager) SoftDeleteAccountContext(ctx context.Context, userID string) error {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return errors.New("invalid user ID")
+	}
+
+	// A pipeline update, to copy the email within the same write. The email
+	// becomes a placeholder unique to the user, since users without one would
+	// collide under the unique email index.
+	err = m.db.UpdateOneContext(ctx, 
+		m.config.DatabaseName,
+		bson.M{"_id": objID, "deleted_at": notDeleted},
+		bson.A{bson.M{"$set": bson.M{
+			"deleted_at":    time.Now(),
+			"deleted_email": "$email",
+			"email":         bson.M{"$concat": bson.A{"deleted:", bson.M{"$toString": "$_id"}}},
+			"token_version": bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$token_version", 0}}, 1}},
+		}}},
+	)
+	m.invalidateUser(userID)
+	if err != nil {
+		return errors.New("failed to delete account")
+	}
+
+	return nil
+}
+
+// DeleteAccount permanently deletes user account
 func (m *Manager) DeleteAccount(userID string) error {
+	return m.DeleteAccountContext(context.Background(), userID)
+}
+
+// DeleteAccountContext is like DeleteAccount but runs under ctx
+func (m *Manager) DeleteAccountContext(ctx context.Context, userID string) error {
 	objID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
 		return errors.New("invalid user ID")
 	}
 
-	err = m.db.DeleteOne(m.config.DatabaseName, bson.M{"_id": objID})
+	err = m.db.DeleteOneContext(ctx, m.config.DatabaseName, bson.M{"_id": objID})
+	m.invalidateUser(userID)
 	if err != nil {
 		return errors.New("failed to delete account")
 	}
@@ -0,0 +1,439 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berkkaradalan/CoreGo/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSoftDeletedUserIsNotFound(t *testing.T) {
+	m := newTestManager(t, &Config{})
+	deleted := mustSignup(t, m, "deleted@example.com", "correct horse battery")
+	active := mustSignup(t, m, "active@example.com", "correct horse battery")
+	if err := m.SoftDeleteAccount(deleted.ID); err != nil {
+		t.Fatalf("SoftDeleteAccount: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		lookup func(id, email string) error
+	}{
+		{"GetUserByID", func(id, _ string) error { _, err := m.GetUserByID(id); return err }},
+		{"GetUserByEmail", func(_, email string) error { _, err := m.GetUserByEmail(email); return err }},
+		{"GetUserPublic", func(id, _ string) error { _, err := m.GetUserPublic(id); return err }},
+		{"GetUsersByIDs", func(id, _ string) error {
+			users, err := m.GetUsersByIDs([]string{id})
+			if err == nil && len(users) == 0 {
+				return ErrUserNotFound
+			}
+			return err
+		}},
+		{"ListUsers", func(id, _ string) error {
+			page, err := m.ListUsers(1, 10)
+			if err != nil {
+				return err
+			}
+			for _, user := range page.Items {
+				if user.ID == id {
+					return nil
+				}
+			}
+			return ErrUserNotFound
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.lookup(active.ID, active.Email); err != nil {
+				t.Fatalf("active user: %v", err)
+			}
+			if err := tt.lookup(deleted.ID, deleted.Email); !errors.Is(err, ErrUserNotFound) {
+				t.Fatalf("soft-deleted user: error = %v, want ErrUserNotFound", err)
+			}
+		})
+	}
+
+	if _, _, err := m.Login(LoginRequest{Email: deleted.Email, Password: "correct horse battery"}); err == nil {
+		t.Fatal("soft-deleted user logged in")
+	}
+}
+
+func TestChangePasswordValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		require bool
+		req     ChangePasswordRequest
+		wantErr error
+	}{
+		{"empty new password", false, ChangePasswordRequest{OldPassword: "old"}, nil},
+		{"confirmation differs", false, ChangePasswordRequest{OldPassword: "old", NewPassword: "new", ConfirmPassword: "other"}, ErrPasswordMismatch},
+		{"confirmation required", true, ChangePasswordRequest{OldPassword: "old", NewPassword: "new"}, ErrPasswordMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Validation fails before the user is loaded, so no database is needed
+			m := newOfflineManager(t, &Config{RequirePasswordConfirmation: tt.require})
+			err := m.ChangePassword("507f1f77bcf86cd799439011", tt.req)
+			if err == nil {
+				t.Fatal("ChangePassword succeeded")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ChangePassword error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	m := newTestManager(t, &Config{PasswordHistorySize: 2, RequirePasswordConfirmation: true})
+	user := mustSignup(t, m, "change@example.com", "password-0")
+
+	tests := []struct {
+		name    string
+		old     string
+		new     string
+		wantErr error
+	}{
+		{"first change", "password-0", "password-1", nil},
+		{"same as current", "password-1", "password-1", ErrPasswordReused},
+		{"in history", "password-1", "password-0", ErrPasswordReused},
+		{"second change", "password-1", "password-2", nil},
+		{"third change", "password-2", "password-3", nil},
+		{"dropped from history", "password-3", "password-0", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := m.ChangePassword(user.ID, ChangePasswordRequest{OldPassword: tt.old, NewPassword: tt.new, ConfirmPassword: tt.new})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ChangePassword error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFlattenPatch(t *testing.T) {
+	tests := []struct {
+		name      string
+		patch     map[string]any
+		wantSet   bson.M
+		wantUnset bson.M
+		wantErr   bool
+	}{
+		{"scalar", map[string]any{"a": 1}, bson.M{"custom.a": 1}, bson.M{}, false},
+		{"null deletes", map[string]any{"a": nil}, bson.M{}, bson.M{"custom.a": ""}, false},
+		{"nested", map[string]any{"a": map[string]any{"b": "x", "c": nil}}, bson.M{"custom.a.b": "x"}, bson.M{"custom.a.c": ""}, false},
+		{"empty object skipped", map[string]any{"a": map[string]any{}, "b": 2}, bson.M{"custom.b": 2}, bson.M{}, false},
+		{"nested empty object skipped", map[string]any{"a": map[string]any{"b": map[string]any{}}}, bson.M{}, bson.M{}, false},
+		{"dotted key", map[string]any{"a.b": 1}, nil, nil, true},
+		{"operator key", map[string]any{"$set": 1}, nil, nil, true},
+		{"empty key", map[string]any{"": 1}, nil, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, unset := bson.M{}, bson.M{}
+			err := flattenPatch("custom", tt.patch, set, unset)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("flattenPatch error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(set, tt.wantSet) {
+				t.Errorf("set = %v, want %v", set, tt.wantSet)
+			}
+			if !reflect.DeepEqual(unset, tt.wantUnset) {
+				t.Errorf("unset = %v, want %v", unset, tt.wantUnset)
+			}
+		})
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   map[string]any
+		patch map[string]any
+		want  map[string]any
+	}{
+		{"nil doc", nil, map[string]any{"a": 1}, map[string]any{"a": 1}},
+		{"delete", map[string]any{"a": 1, "b": 2}, map[string]any{"a": nil}, map[string]any{"b": 2}},
+		{"merge nested", map[string]any{"a": map[string]any{"b": 1}}, map[string]any{"a": map[string]any{"c": 2}}, map[string]any{"a": map[string]any{"b": 1, "c": 2}}},
+		{"empty object keeps value", map[string]any{"a": map[string]any{"b": 1}}, map[string]any{"a": map[string]any{}}, map[string]any{"a": map[string]any{"b": 1}}},
+		{"empty object not created", map[string]any{}, map[string]any{"a": map[string]any{}}, map[string]any{}},
+		{"delete below missing key", map[string]any{}, map[string]any{"a": map[string]any{"b": nil}}, map[string]any{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := applyPatch(tt.doc, tt.patch); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("applyPatch = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateProfileMerge(t *testing.T) {
+	m := newTestManager(t, &Config{})
+
+	tests := []struct {
+		name   string
+		stored any
+		patch  map[string]any
+		want   map[string]any
+	}{
+		{"adds key", bson.M{"a": "x"}, map[string]any{"b": "y"}, map[string]any{"a": "x", "b": "y"}},
+		{"empty object keeps value", bson.M{"a": bson.M{"b": "x"}}, map[string]any{"a": map[string]any{}}, map[string]any{"a": map[string]any{"b": "x"}}},
+		{"null custom", nil, map[string]any{"a": "x"}, map[string]any{"a": "x"}},
+		{"null custom nested", nil, map[string]any{"a": map[string]any{"b": "x"}}, map[string]any{"a": map[string]any{"b": "x"}}},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := mustSignup(t, m, string(rune('a'+i))+"-merge@example.com", "correct horse battery")
+			objID, _ := primitive.ObjectIDFromHex(user.ID)
+			if err := m.db.UpdateOne(m.config.DatabaseName, bson.M{"_id": objID}, bson.M{"$set": bson.M{"custom": tt.stored}}); err != nil {
+				t.Fatalf("store custom: %v", err)
+			}
+
+			updated, err := m.UpdateProfile(user.ID, UpdateProfileRequest{Custom: tt.patch, Merge: true})
+			if err != nil {
+				t.Fatalf("UpdateProfile: %v", err)
+			}
+			if got := normalizeCustom(updated.Custom); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("custom = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// normalizeCustom turns the nested documents custom data decodes into back into plain maps
+func normalizeCustom(custom map[string]any) map[string]any {
+	out := make(map[string]any, len(custom))
+	for k, v := range custom {
+		switch v := v.(type) {
+		case primitive.M:
+			out[k] = normalizeCustom(v)
+		case map[string]any:
+			out[k] = normalizeCustom(v)
+		case primitive.D:
+			out[k] = normalizeCustom(v.Map())
+		default:
+			out[k] = v
+		}
+	}
+	return out
+}
+
+func TestPasswordChangeRevokesTokens(t *testing.T) {
+	m := newTestManager(t, &Config{})
+
+	tests := []struct {
+		name   string
+		change func(userID string) error
+	}{
+		{"change password", func(userID string) error {
+			return m.ChangePassword(userID, ChangePasswordRequest{OldPassword: "correct horse battery", NewPassword: "battery staple horse"})
+		}},
+		{"reset password", func(userID string) error {
+			token, err := m.issueOneTimeToken(t.Context(), m.config.Collections.PasswordResets, userID, time.Hour)
+			if err != nil {
+				return err
+			}
+			return m.ResetPassword(token, "battery staple horse")
+		}},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			email := fmt.Sprintf("sessions-%d@example.com", i)
+			user := mustSignup(t, m, email, "correct horse battery")
+			_, oldToken, err := m.Login(LoginRequest{Email: email, Password: "correct horse battery"})
+			if err != nil {
+				t.Fatalf("Login: %v", err)
+			}
+
+			if err := tt.change(user.ID); err != nil {
+				t.Fatalf("change: %v", err)
+			}
+
+			if _, err := m.ValidateToken(oldToken); !errors.Is(err, ErrTokenRevoked) {
+				t.Fatalf("old token after change = %v, want ErrTokenRevoked", err)
+			}
+			_, newToken, err := m.Login(LoginRequest{Email: email, Password: "battery staple horse"})
+			if err != nil {
+				t.Fatalf("Login with new password: %v", err)
+			}
+			if _, err := m.ValidateToken(newToken); err != nil {
+				t.Fatalf("new token: %v", err)
+			}
+		})
+	}
+}
+
+func TestGetUserPublic(t *testing.T) {
+	m := newTestManager(t, &Config{})
+	user, _, err := m.Signup(SignupRequest{Email: "public@example.com", Password: "correct horse battery", Custom: map[string]any{"name": "Ada"}})
+	if err != nil {
+		t.Fatalf("Signup: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		id      string
+		wantErr error
+	}{
+		{"existing user", user.ID, nil},
+		{"unknown ID", primitive.NewObjectID().Hex(), ErrUserNotFound},
+		{"malformed ID", "not-an-id", errors.New("invalid user ID")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			public, err := m.GetUserPublic(tt.id)
+			if errString(err) != errString(tt.wantErr) {
+				t.Fatalf("GetUserPublic error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if public.ID != user.ID || public.Email != user.Email || public.Custom["name"] != "Ada" {
+				t.Fatalf("GetUserPublic = %+v", public)
+			}
+			body, err := json.Marshal(public)
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			if strings.Contains(string(body), "password") || strings.Contains(string(body), "$2a$") {
+				t.Fatalf("public user leaks the password: %s", body)
+			}
+		})
+	}
+}
+
+func TestGetUsersByIDs(t *testing.T) {
+	m := newTestManager(t, &Config{})
+	a := mustSignup(t, m, "a@example.com", "correct horse battery")
+	b := mustSignup(t, m, "b@example.com", "correct horse battery")
+	deleted := mustSignup(t, m, "deleted@example.com", "correct horse battery")
+	if err := m.SoftDeleteAccount(deleted.ID); err != nil {
+		t.Fatalf("SoftDeleteAccount: %v", err)
+	}
+	missing := primitive.NewObjectID().Hex()
+
+	tests := []struct {
+		name        string
+		ids         []string
+		wantEmails  []string
+		wantInvalid []string
+	}{
+		{"none", nil, []string{}, nil},
+		{"in request order", []string{b.ID, a.ID}, []string{"b@example.com", "a@example.com"}, nil},
+		{"duplicates once", []string{a.ID, a.ID, b.ID}, []string{"a@example.com", "b@example.com"}, nil},
+		{"missing and deleted skipped", []string{missing, a.ID, deleted.ID}, []string{"a@example.com"}, nil},
+		{"malformed reported", []string{"bad", a.ID, "worse"}, []string{"a@example.com"}, []string{"bad", "worse"}},
+		{"only malformed", []string{"bad"}, []string{}, []string{"bad"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users, err := m.GetUsersByIDs(tt.ids)
+
+			var invalid *InvalidIDsError
+			switch {
+			case tt.wantInvalid == nil && err != nil:
+				t.Fatalf("GetUsersByIDs: %v", err)
+			case tt.wantInvalid != nil && !errors.As(err, &invalid):
+				t.Fatalf("GetUsersByIDs error = %v, want an *InvalidIDsError", err)
+			case tt.wantInvalid != nil && !reflect.DeepEqual(invalid.IDs, tt.wantInvalid):
+				t.Fatalf("invalid IDs = %v, want %v", invalid.IDs, tt.wantInvalid)
+			}
+
+			emails := make([]string, 0, len(users))
+			for _, user := range users {
+				if user.Password != "" {
+					t.Fatalf("user %s returned with a password hash", user.Email)
+				}
+				emails = append(emails, user.Email)
+			}
+			if !reflect.DeepEqual(emails, tt.wantEmails) {
+				t.Fatalf("users = %v, want %v", emails, tt.wantEmails)
+			}
+		})
+	}
+}
+
+func TestGetUsersByIDsStrictCollections(t *testing.T) {
+	db := newTestDB(t)
+	strict, err := database.NewMongoDB(&database.MongoConfig{
+		URL:               os.Getenv(testMongoURL),
+		Database:          db.Database().Name(),
+		StrictCollections: true,
+	})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { strict.Disconnect() })
+
+	m, err := New(&Config{Secret: "test-secret", EnsureIndexes: Bool(false)}, strict)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	// Nobody signed up, so the users collection doesn't exist
+	_, err = m.GetUsersByIDs([]string{primitive.NewObjectID().Hex()})
+	if !errors.Is(err, ErrLookupFailed) || !errors.Is(err, database.ErrCollectionNotFound) {
+		t.Fatalf("GetUsersByIDs error = %v, want ErrLookupFailed wrapping ErrCollectionNotFound", err)
+	}
+}
+
+func TestListUsers(t *testing.T) {
+	m := newTestManager(t, &Config{})
+	for _, email := range []string{"one@example.com", "two@example.com", "three@example.com"} {
+		mustSignup(t, m, email, "correct horse battery")
+	}
+	deleted := mustSignup(t, m, "deleted@example.com", "correct horse battery")
+	if err := m.SoftDeleteAccount(deleted.ID); err != nil {
+		t.Fatalf("SoftDeleteAccount: %v", err)
+	}
+
+	tests := []struct {
+		page, pageSize int
+		wantItems      int
+	}{
+		{1, 2, 2},
+		{2, 2, 1},
+		{3, 2, 0},
+	}
+
+	seen := make(map[string]bool)
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("page %d", tt.page), func(t *testing.T) {
+			page, err := m.ListUsers(tt.page, tt.pageSize)
+			if err != nil {
+				t.Fatalf("ListUsers: %v", err)
+			}
+			if len(page.Items) != tt.wantItems || page.Total != 3 || page.TotalPages != 2 {
+				t.Fatalf("page has %d items, total %d, %d pages, want %d, 3, 2", len(page.Items), page.Total, page.TotalPages, tt.wantItems)
+			}
+			for _, user := range page.Items {
+				if user.Email == deleted.Email || seen[user.Email] {
+					t.Fatalf("unexpected user %s on page %d", user.Email, tt.page)
+				}
+				seen[user.Email] = true
+			}
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package auth
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"time"
 
@@ -8,6 +11,15 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// validatePassword applies the rules every new password must meet, on
+// signup, password change and reset
+func validatePassword(password string) error {
+	if password == "" {
+		return errors.New("password is required")
+	}
+	return nil
+}
+
 // HashPassword hashes password using bcrypt
 func HashPassword(password string) (string, error) {
 	hashedBytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -23,44 +35,235 @@ func VerifyPassword(hashedPassword, password string) bool {
 	return err == nil
 }
 
-// GenerateToken creates a JWT token for the user
+// GenerateToken creates a JWT token for the user without touching the
+// database. The token carries token version 0, so it is rejected once the
+// user's tokens have been revoked, e.g. by a password change. When you hold
+// the user, pass User.TokenVersion through GenerateTokenWithOptions instead.
 func (m *Manager) GenerateToken(userID string) (string, error) {
+	return m.generateToken(userID, 0)
+}
+
+// generateToken creates a JWT token that expires after Config.TokenExpiry
+func (m *Manager) generateToken(userID string, tokenVersion int) (string, error) {
+	return m.signToken(userID, tokenVersion, time.Duration(m.config.TokenExpiry)*time.Minute, time.Time{})
+}
+
+// TokenOptions customizes a token issued by GenerateTokenWithOptions
+type TokenOptions struct {
+	// Optional: lifetime of the token (default: Config.TokenExpiry), counted
+	// from NotBefore when that is in the future
+	Expiry time.Duration
+
+	// Optional: the token is rejected before this time ("nbf" claim), e.g. for
+	// scheduled access. Config.ClockSkew applies.
+	NotBefore time.Time
+
+	// Optional: the user's current User.TokenVersion (default: 0). Tokens with
+	// an older version are rejected as revoked.
+	TokenVersion int
+}
+
+// GenerateTokenWithOptions is like GenerateToken with a custom expiry,
+// not-before time or token version
+func (m *Manager) GenerateTokenWithOptions(userID string, opts TokenOptions) (string, error) {
+	expiry := opts.Expiry
+	if expiry <= 0 {
+		expiry = time.Duration(m.config.TokenExpiry) * time.Minute
+	}
+	return m.signToken(userID, opts.TokenVersion, expiry, opts.NotBefore)
+}
+
+// signToken creates a JWT token carrying a unique jti and the user's token
+// version. A non-zero notBefore sets "nbf" and delays the expiry accordingly.
+func (m *Manager) signToken(userID string, tokenVersion int, expiry time.Duration, notBefore time.Time) (string, error) {
+	jti, err := generateRandomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	validFrom := now
+	if notBefore.After(now) {
+		validFrom = notBefore
+	}
+
 	claims := jwt.MapClaims{
 		"user_id": userID,
-		"exp":     time.Now().Add(time.Duration(m.config.TokenExpiry) * time.Minute).Unix(),
-		"iat":     time.Now().Unix(),
+		"jti":     jti,
+		"ver":     tokenVersion,
+		"exp":     validFrom.Add(expiry).Unix(),
+		"iat":     now.Unix(),
+	}
+	if !notBefore.IsZero() {
+		claims["nbf"] = notBefore.Unix()
+	}
+	return m.signClaims(claims)
+}
+
+// signClaims adds the configured issuer, audience and key ID and signs claims
+func (m *Manager) signClaims(claims jwt.MapClaims) (string, error) {
+	if m.config.Issuer != "" {
+		claims["iss"] = m.config.Issuer
+	}
+	if m.config.Audience != "" {
+		claims["aud"] = m.config.Audience
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	if m.config.KeyID != "" {
+		token.Header["kid"] = m.config.KeyID
+	}
 	return token.SignedString([]byte(m.config.Secret))
 }
 
+// Claims are the validated claims of a token issued by the Manager
+type Claims struct {
+	UserID    string
+	TokenID   string // jti, see RevokeToken
+	Version   int
+	IssuedAt  time.Time
+	NotBefore time.Time // zero when the token has no "nbf" claim
+	ExpiresAt time.Time
+	Issuer    string
+	Audience  []string
+
+	// Raw holds every claim, including ones added outside this package
+	Raw jwt.MapClaims
+}
+
+// ParseToken fully validates a token, including signature, expiry, issuer,
+// audience and revocation, and returns its claims. Use it to authenticate
+// outside the Gin middleware, e.g. WebSocket handshakes.
+func (m *Manager) ParseToken(tokenString string) (*Claims, error) {
+	return m.ParseTokenContext(context.Background(), tokenString)
+}
+
+// ParseTokenContext is like ParseToken but runs under ctx
+func (m *Manager) ParseTokenContext(ctx context.Context, tokenString string) (*Claims, error) {
+	raw, _, err := m.validateToken(ctx, tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &Claims{Raw: raw}
+	claims.UserID, _ = raw["user_id"].(string)
+	claims.TokenID, _ = raw["jti"].(string)
+	if ver, ok := raw["ver"].(float64); ok {
+		claims.Version = int(ver)
+	}
+	if iat, err := raw.GetIssuedAt(); err == nil && iat != nil {
+		claims.IssuedAt = iat.Time
+	}
+	if nbf, err := raw.GetNotBefore(); err == nil && nbf != nil {
+		claims.NotBefore = nbf.Time
+	}
+	if exp, err := raw.GetExpirationTime(); err == nil && exp != nil {
+		claims.ExpiresAt = exp.Time
+	}
+	claims.Issuer, _ = raw.GetIssuer()
+	claims.Audience, _ = raw.GetAudience()
+
+	return claims, nil
+}
+
 // ValidateToken validates JWT token and returns user ID
 func (m *Manager) ValidateToken(tokenString string) (string, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("invalid signing method")
-		}
-		return []byte(m.config.Secret), nil
-	})
-
+	claims, _, err := m.validateToken(context.Background(), tokenString)
 	if err != nil {
 		return "", err
 	}
+	return claims["user_id"].(string), nil
+}
+
+// validateToken validates JWT token, including revocation, and returns its
+// claims and the user loaded to check the token version. The user is nil for
+// test tokens, which skip that check.
+func (m *Manager) validateToken(ctx context.Context, tokenString string) (_ jwt.MapClaims, _ *User, err error) {
+	defer func() {
+		m.config.Metrics.IncTokenValidation(err == nil)
+	}()
+
+	token, err := jwt.Parse(tokenString, m.keyFunc, m.parserOptions()...)
+
+	if err != nil {
+		return nil, nil, err
+	}
 
 	if !token.Valid {
-		return "", errors.New("invalid token")
+		return nil, nil, errors.New("invalid token")
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
-		return "", errors.New("invalid token claims")
+		return nil, nil, errors.New("invalid token claims")
 	}
 
 	userID, ok := claims["user_id"].(string)
 	if !ok {
-		return "", errors.New("user_id not found in token")
+		return nil, nil, errors.New("user_id not found in token")
+	}
+
+	if isTest, _ := claims["test"].(bool); isTest && testTokensEnabled {
+		return claims, nil, nil
+	}
+
+	user, err := m.checkRevocation(ctx, userID, claims)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return claims, user, nil
+}
+
+// keyFunc selects the verification secret from the token's kid header
+func (m *Manager) keyFunc(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, errors.New("invalid signing method")
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if kid == m.config.KeyID {
+		// Tokens without kid may also predate rotation
+		if previous, ok := m.config.PreviousKeys[""]; ok && kid == "" {
+			return jwt.VerificationKeySet{Keys: []jwt.VerificationKey{
+				[]byte(m.config.Secret),
+				[]byte(previous),
+			}}, nil
+		}
+		return []byte(m.config.Secret), nil
 	}
 
-	return userID, nil
-}
\ No newline at end of file
+	if previous, ok := m.config.PreviousKeys[kid]; ok {
+		return []byte(previous), nil
+	}
+	return nil, errors.New("unknown signing key")
+}
+
+// Bool returns a pointer to v, for optional boolean config fields
+func Bool(v bool) *bool {
+	return &v
+}
+
+// generateRandomToken returns n random bytes encoded as hex
+func generateRandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// parserOptions returns the JWT validation options derived from config
+func (m *Manager) parserOptions() []jwt.ParserOption {
+	opts := []jwt.ParserOption{jwt.WithIssuedAt()}
+	if m.config.ClockSkew > 0 {
+		opts = append(opts, jwt.WithLeeway(m.config.ClockSkew))
+	}
+	if m.config.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(m.config.Issuer))
+	}
+	if m.config.Audience != "" {
+		opts = append(opts, jwt.WithAudience(m.config.Audience))
+	}
+	return opts
+}
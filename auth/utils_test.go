@@ -0,0 +1,269 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// parseOffline runs the signature and claim checks of validateToken, without
+// the revocation lookup
+func parseOffline(m *Manager, token string) error {
+	_, err := jwt.Parse(token, m.keyFunc, m.parserOptions()...)
+	return err
+}
+
+func TestIssuerAndAudience(t *testing.T) {
+	tests := []struct {
+		name      string
+		signer    *Config
+		validator *Config
+		wantErr   bool
+	}{
+		{"none configured", &Config{}, &Config{}, false},
+		{"matching", &Config{Issuer: "api", Audience: "web"}, &Config{Issuer: "api", Audience: "web"}, false},
+		{"other issuer", &Config{Issuer: "other", Audience: "web"}, &Config{Issuer: "api", Audience: "web"}, true},
+		{"other audience", &Config{Issuer: "api", Audience: "mobile"}, &Config{Issuer: "api", Audience: "web"}, true},
+		{"missing issuer", &Config{Audience: "web"}, &Config{Issuer: "api", Audience: "web"}, true},
+		{"missing audience", &Config{Issuer: "api"}, &Config{Issuer: "api", Audience: "web"}, true},
+		{"extra claims ignored", &Config{Issuer: "api", Audience: "web"}, &Config{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer := newOfflineManager(t, tt.signer)
+			validator := newOfflineManager(t, tt.validator)
+
+			token, err := signer.signToken("user-1", 0, time.Hour, time.Time{})
+			if err != nil {
+				t.Fatalf("signToken: %v", err)
+			}
+			if err := parseOffline(validator, token); (err != nil) != tt.wantErr {
+				t.Fatalf("parse error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClockSkew(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		skew    time.Duration
+		claims  jwt.MapClaims
+		wantErr bool
+	}{
+		{"valid", 0, jwt.MapClaims{"exp": now.Add(time.Hour).Unix(), "iat": now.Unix()}, false},
+		{"expired without skew", 0, jwt.MapClaims{"exp": now.Add(-30 * time.Second).Unix()}, true},
+		{"expired within skew", time.Minute, jwt.MapClaims{"exp": now.Add(-30 * time.Second).Unix()}, false},
+		{"expired beyond skew", time.Minute, jwt.MapClaims{"exp": now.Add(-2 * time.Minute).Unix()}, true},
+		{"issued in the future without skew", 0, jwt.MapClaims{"exp": now.Add(time.Hour).Unix(), "iat": now.Add(30 * time.Second).Unix()}, true},
+		{"issued in the future within skew", time.Minute, jwt.MapClaims{"exp": now.Add(time.Hour).Unix(), "iat": now.Add(30 * time.Second).Unix()}, false},
+		{"not yet valid within skew", time.Minute, jwt.MapClaims{"exp": now.Add(time.Hour).Unix(), "nbf": now.Add(30 * time.Second).Unix()}, false},
+		{"not yet valid beyond skew", time.Minute, jwt.MapClaims{"exp": now.Add(time.Hour).Unix(), "nbf": now.Add(2 * time.Minute).Unix()}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newOfflineManager(t, &Config{ClockSkew: tt.skew})
+			tt.claims["user_id"] = "user-1"
+			token, err := m.signClaims(tt.claims)
+			if err != nil {
+				t.Fatalf("signClaims: %v", err)
+			}
+			if err := parseOffline(m, token); (err != nil) != tt.wantErr {
+				t.Fatalf("parse error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	current := &Config{Secret: "new-secret", KeyID: "v2", PreviousKeys: map[string]string{"v1": "old-secret", "": "legacy-secret"}}
+
+	tests := []struct {
+		name      string
+		signer    *Config
+		validator *Config
+		wantErr   bool
+	}{
+		{"current key", &Config{Secret: "new-secret", KeyID: "v2"}, current, false},
+		{"previous key", &Config{Secret: "old-secret", KeyID: "v1"}, current, false},
+		{"legacy token without kid", &Config{Secret: "legacy-secret"}, current, false},
+		{"unknown kid", &Config{Secret: "old-secret", KeyID: "v0"}, current, true},
+		{"known kid, wrong secret", &Config{Secret: "forged", KeyID: "v1"}, current, true},
+		{"no kid, wrong secret", &Config{Secret: "forged"}, current, true},
+		{"retired key", &Config{Secret: "old-secret", KeyID: "v1"}, &Config{Secret: "new-secret", KeyID: "v2"}, true},
+		{"no kid without rotation", &Config{Secret: "new-secret"}, &Config{Secret: "new-secret"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer := newOfflineManager(t, tt.signer)
+			validator := newOfflineManager(t, tt.validator)
+
+			token, err := signer.signToken("user-1", 0, time.Hour, time.Time{})
+			if err != nil {
+				t.Fatalf("signToken: %v", err)
+			}
+			if err := parseOffline(validator, token); (err != nil) != tt.wantErr {
+				t.Fatalf("parse error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeyIDInPreviousKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *Config
+		wantErr bool
+	}{
+		{"distinct", &Config{KeyID: "v2", PreviousKeys: map[string]string{"v1": "old"}}, false},
+		{"current kid reused", &Config{KeyID: "v2", PreviousKeys: map[string]string{"v2": "old"}}, true},
+		{"legacy entry without KeyID", &Config{PreviousKeys: map[string]string{"": "old"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.Secret = "test-secret"
+			_, err := New(tt.config, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseToken(t *testing.T) {
+	m := newTestManager(t, &Config{Issuer: "api", Audience: "web", TokenExpiry: 30})
+	user := mustSignup(t, m, "claims@example.com", "correct horse battery")
+
+	tests := []struct {
+		name       string
+		token      func() (string, error)
+		wantExpiry time.Duration
+		wantErr    bool
+	}{
+		{"login token", func() (string, error) { return m.GenerateToken(user.ID) }, 30 * time.Minute, false},
+		{"custom expiry", func() (string, error) {
+			return m.GenerateTokenWithOptions(user.ID, TokenOptions{Expiry: 2 * time.Hour})
+		}, 2 * time.Hour, false},
+		{"tampered", func() (string, error) {
+			token, err := m.GenerateToken(user.ID)
+			return token + "x", err
+		}, 0, true},
+		{"other secret", func() (string, error) {
+			return newOfflineManager(t, &Config{Secret: "other", Issuer: "api", Audience: "web"}).signToken(user.ID, 0, time.Hour, time.Time{})
+		}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := tt.token()
+			if err != nil {
+				t.Fatalf("issue token: %v", err)
+			}
+			claims, err := m.ParseToken(token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseToken error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if claims.UserID != user.ID || claims.TokenID == "" || claims.Version != user.TokenVersion {
+				t.Fatalf("claims = %+v", claims)
+			}
+			if claims.Issuer != "api" || len(claims.Audience) != 1 || claims.Audience[0] != "web" {
+				t.Fatalf("issuer, audience = %q, %v, want api, [web]", claims.Issuer, claims.Audience)
+			}
+			if !claims.NotBefore.IsZero() {
+				t.Fatalf("NotBefore = %v, want zero", claims.NotBefore)
+			}
+			if got := claims.ExpiresAt.Sub(claims.IssuedAt); got != tt.wantExpiry {
+				t.Fatalf("lifetime = %v, want %v", got, tt.wantExpiry)
+			}
+			if claims.Raw["user_id"] != user.ID {
+				t.Fatalf("Raw = %v", claims.Raw)
+			}
+		})
+	}
+}
+
+func TestGenerateTokenWithoutDatabase(t *testing.T) {
+	m := newOfflineManager(t, &Config{})
+
+	tests := []struct {
+		name        string
+		token       func() (string, error)
+		wantVersion float64
+	}{
+		{"GenerateToken", func() (string, error) { return m.GenerateToken("user-1") }, 0},
+		{"with options", func() (string, error) {
+			return m.GenerateTokenWithOptions("user-1", TokenOptions{TokenVersion: 3})
+		}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := tt.token()
+			if err != nil {
+				t.Fatalf("issue token: %v", err)
+			}
+			claims := jwt.MapClaims{}
+			if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if claims["user_id"] != "user-1" || claims["ver"] != tt.wantVersion {
+				t.Fatalf("claims = %v, want user-1 at version %v", claims, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestSignTokenNotBefore(t *testing.T) {
+	m := newOfflineManager(t, &Config{})
+	now := time.Now().Truncate(time.Second)
+
+	tests := []struct {
+		name      string
+		expiry    time.Duration
+		notBefore time.Time
+		wantNbf   bool
+		wantExp   time.Time // approximately, to the second
+		wantValid bool
+	}{
+		{"no nbf", time.Hour, time.Time{}, false, now.Add(time.Hour), true},
+		{"nbf in the past", time.Hour, now.Add(-time.Minute), true, now.Add(time.Hour), true},
+		// The lifetime counts from nbf, so scheduled tokens aren't shortened
+		{"nbf in the future", time.Hour, now.Add(2 * time.Hour), true, now.Add(3 * time.Hour), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := m.signToken("user-1", 0, tt.expiry, tt.notBefore)
+			if err != nil {
+				t.Fatalf("signToken: %v", err)
+			}
+			claims := jwt.MapClaims{}
+			if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+				t.Fatalf("ParseUnverified: %v", err)
+			}
+
+			nbf, _ := claims.GetNotBefore()
+			if (nbf != nil) != tt.wantNbf || (nbf != nil && !nbf.Time.Equal(tt.notBefore)) {
+				t.Fatalf("nbf = %v, want %v", nbf, tt.notBefore)
+			}
+			exp, _ := claims.GetExpirationTime()
+			if diff := exp.Time.Sub(tt.wantExp); diff < -time.Second || diff > time.Second {
+				t.Fatalf("exp = %v, want about %v", exp.Time, tt.wantExp)
+			}
+			if err := parseOffline(m, token); (err == nil) != tt.wantValid {
+				t.Fatalf("parse error = %v, want valid %v", err, tt.wantValid)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package corego
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/berkkaradalan/CoreGo/auth"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// BindQuery binds query parameters into dst using its `form` tags and runs
+// the `binding` tag validations. On failure it writes a 400 response with the
+// standard error envelope, listing bad parameters in fields, and returns false.
+func BindQuery(c *gin.Context, dst any) bool {
+	return bind(c, dst, c.Request.URL.Query(), "form")
+}
+
+// BindURI is BindQuery for path parameters, using `uri` tags
+func BindURI(c *gin.Context, dst any) bool {
+	params := make(map[string][]string, len(c.Params))
+	for _, param := range c.Params {
+		params[param.Key] = []string{param.Value}
+	}
+	return bind(c, dst, params, "uri")
+}
+
+// Helper method
+func bind(c *gin.Context, dst any, values map[string][]string, tag string) bool {
+	err := bindValues(dst, values, tag)
+	var fields auth.FieldErrors
+	if errors.As(err, &fields) {
+		auth.RespondValidationError(c, fields)
+		return false
+	}
+	if err != nil {
+		auth.RespondError(c, 400, auth.CodeBadRequest, err.Error())
+		return false
+	}
+	return true
+}
+
+// bindValues maps values into dst and validates it, reporting per-parameter
+// problems as auth.FieldErrors keyed by the tag name
+func bindValues(dst any, values map[string][]string, tag string) error {
+	if err := binding.MapFormWithTag(dst, values, tag); err != nil {
+		// Gin's error doesn't name the parameter, so find the ones that fail alone
+		if fields := invalidFields(dst, values, tag); len(fields) > 0 {
+			return fields
+		}
+		return err
+	}
+
+	err := binding.Validator.ValidateStruct(dst)
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return err
+	}
+
+	fields := auth.FieldErrors{}
+	for _, fe := range validationErrs {
+		fields[tagName(dst, fe.StructField(), tag)] = validationMessage(fe)
+	}
+	return fields
+}
+
+// invalidFields binds each parameter on its own into a fresh value of dst's type
+func invalidFields(dst any, values map[string][]string, tag string) auth.FieldErrors {
+	typ := reflect.TypeOf(dst)
+	if typ.Kind() != reflect.Pointer || typ.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+
+	fields := auth.FieldErrors{}
+	for key, value := range values {
+		probe := reflect.New(typ.Elem()).Interface()
+		if err := binding.MapFormWithTag(probe, map[string][]string{key: value}, tag); err != nil {
+			fields[key] = typeMessage(typ.Elem(), key, tag)
+		}
+	}
+	return fields
+}
+
+// typeMessage describes the type expected for the field tagged key
+func typeMessage(typ reflect.Type, key, tag string) string {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if name, _, _ := strings.Cut(field.Tag.Get(tag), ","); name != key {
+			continue
+		}
+		kind := field.Type.Kind()
+		if kind == reflect.Slice || kind == reflect.Pointer {
+			kind = field.Type.Elem().Kind()
+		}
+		switch kind {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return "must be an integer"
+		case reflect.Float32, reflect.Float64:
+			return "must be a number"
+		case reflect.Bool:
+			return "must be true or false"
+		}
+	}
+	return "has an invalid value"
+}
+
+// tagName returns the tag name of dst's field, or the field name without one
+func tagName(dst any, field, tag string) string {
+	typ := reflect.TypeOf(dst)
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return field
+	}
+	structField, ok := typ.FieldByName(field)
+	if !ok {
+		return field
+	}
+	name, _, _ := strings.Cut(structField.Tag.Get(tag), ",")
+	if name == "" || name == "-" {
+		return field
+	}
+	return name
+}
+
+// Helper method
+func validationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "min", "gte":
+		return "must be at least " + fe.Param()
+	case "max", "lte":
+		return "must be at most " + fe.Param()
+	case "oneof":
+		return "must be one of " + fe.Param()
+	default:
+		return "failed " + fe.Tag() + " validation"
+	}
+}
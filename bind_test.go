@@ -0,0 +1,93 @@
+package corego
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type listQuery struct {
+	Page   int    `form:"page" binding:"omitempty,min=1"`
+	Size   int    `form:"size" binding:"omitempty,max=100"`
+	Sort   string `form:"sort" binding:"omitempty,oneof=asc desc"`
+	Active bool   `form:"active"`
+	Search string `form:"q" binding:"required"`
+}
+
+func TestBindQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantBody   string
+	}{
+		{"valid", "?q=ada&page=2&size=10&sort=desc&active=true", 200, "ada 2 10 desc true"},
+		{"optional omitted", "?q=ada", 200, "ada 0 0  false"},
+		{"missing required", "?page=2", 400, `{"error":{"code":"validation_failed","message":"validation failed","fields":{"q":"is required"}}}`},
+		{"above max", "?q=ada&page=0&size=500", 400, `{"error":{"code":"validation_failed","message":"validation failed","fields":{"size":"must be at most 100"}}}`},
+		{"not in oneof", "?q=ada&sort=up", 400, `{"error":{"code":"validation_failed","message":"validation failed","fields":{"sort":"must be one of asc desc"}}}`},
+		{"wrong types", "?q=ada&page=two&active=maybe", 400, `{"error":{"code":"validation_failed","message":"validation failed","fields":{"active":"must be true or false","page":"must be an integer"}}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/users", func(c *gin.Context) {
+				var q listQuery
+				if !BindQuery(c, &q) {
+					return
+				}
+				c.String(200, "%s %d %d %s %v", q.Search, q.Page, q.Size, q.Sort, q.Active)
+			})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/users"+tt.query, nil))
+			if w.Code != tt.wantStatus || w.Body.String() != tt.wantBody {
+				t.Fatalf("response = %d %s, want %d %s", w.Code, w.Body.String(), tt.wantStatus, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestBindURI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	type itemURI struct {
+		ID   int    `uri:"id" binding:"required,min=1"`
+		Slug string `uri:"slug"`
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"valid", "/items/42/widget", 200, "42 widget"},
+		{"not an integer", "/items/abc/widget", 400, `{"error":{"code":"validation_failed","message":"validation failed","fields":{"id":"must be an integer"}}}`},
+		{"below min", "/items/0/widget", 400, `{"error":{"code":"validation_failed","message":"validation failed","fields":{"id":"is required"}}}`},
+		{"negative", "/items/-3/widget", 400, `{"error":{"code":"validation_failed","message":"validation failed","fields":{"id":"must be at least 1"}}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/items/:id/:slug", func(c *gin.Context) {
+				var uri itemURI
+				if !BindURI(c, &uri) {
+					return
+				}
+				c.String(200, "%d %s", uri.ID, uri.Slug)
+			})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+			if w.Code != tt.wantStatus || w.Body.String() != tt.wantBody {
+				t.Fatalf("response = %d %s, want %d %s", w.Code, w.Body.String(), tt.wantStatus, tt.wantBody)
+			}
+		})
+	}
+}
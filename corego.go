@@ -1,6 +1,10 @@
 package corego
 
 import (
+	"context"
+	"errors"
+	"fmt"
+
 	"github.com/berkkaradalan/CoreGo/auth"
 	"github.com/berkkaradalan/CoreGo/database"
 	"github.com/berkkaradalan/CoreGo/env"
@@ -12,21 +16,38 @@ type Config struct {
 	Auth  		*auth.Config
 }
 
+var (
+	ErrNoDatabase        = errors.New("no database configured")
+	ErrAuthRequiresMongo = errors.New("auth requires MongoDB")
+)
+
 type Core struct {
 	Env 		*env.Env
 	Mongo		*database.MongoDB
 	Postgres	*database.PostgresDB
 	Auth		*auth.Manager
+
+	config		*Config
+	ctx			context.Context
+	cancel		context.CancelFunc
 }
 
-func New(config *Config) (*Core, error){
+func New(config *Config) (_ *Core, err error){
 	core := &Core{
 		Env: env.LoadEnv(),
 	}
+	core.ctx, core.cancel = context.WithCancel(context.Background())
+	defer func() {
+		// Don't leak the connections opened before the failure
+		if err != nil {
+			core.Close()
+		}
+	}()
 
 	if config == nil {
 		config = &Config{}
 	}
+	core.config = config
 
 	if config.Mongo != nil {
 		mongo, err := database.NewMongoDB(config.Mongo)
@@ -69,12 +90,65 @@ func New(config *Config) (*Core, error){
 		core.Auth = authManager
 	}
 
+	if err := core.Validate(); err != nil {
+		return nil, err
+	}
+
 	return core, nil
 }
 
+// MustNew is like New but panics if initialization fails. It is meant for
+// program startup, e.g. in main or a package-level var; use New anywhere a
+// failure should be handled.
+func MustNew(config *Config) *Core {
+	core, err := New(config)
+	if err != nil {
+		panic("corego: " + err.Error())
+	}
+	return core
+}
+
+// Validate reports configuration that New would otherwise silently ignore,
+// such as Auth without a MongoDB connection. All problems are joined into
+// one error; it returns nil when the configuration is consistent.
+func (c *Core) Validate() error {
+	var errs []error
+
+	if c.config.Auth != nil && c.Auth == nil {
+		switch {
+		case c.Mongo == nil && c.Postgres == nil:
+			errs = append(errs, fmt.Errorf("%w: auth is configured, set Config.Mongo or MONGODB_CONNECTION_URL", ErrNoDatabase))
+		case c.Mongo == nil:
+			errs = append(errs, fmt.Errorf("%w: users are stored in MongoDB, Postgres alone is not enough", ErrAuthRequiresMongo))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Context returns a context that is cancelled when Close is called. Use it for
+// background work, such as Listen or Watch loops, that should stop with the Core.
+func (c *Core) Context() context.Context {
+	return c.ctx
+}
+
+// Close cancels Context and disconnects MongoDB and Postgres. Running Listen and
+// Watch subscriptions return once their connection is closed. It is safe to call
+// on a nil or zero Core, e.g. the result of a failed New.
 func (c *Core) Close() error {
+	if c == nil {
+		return nil
+	}
+	if c.cancel != nil {
+		c.cancel()
+	}
+
+	var errs []error
 	if c.Mongo != nil {
-		return c.Mongo.Disconnect()
+		errs = append(errs, c.Mongo.Disconnect())
+	}
+	if c.Postgres != nil {
+		errs = append(errs, c.Postgres.Disconnect())
 	}
-	return nil
+	return errors.Join(errs...)
 }
\ No newline at end of file
@@ -0,0 +1,198 @@
+package corego
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/berkkaradalan/CoreGo/auth"
+	"github.com/berkkaradalan/CoreGo/database"
+)
+
+func TestCloseWithoutNew(t *testing.T) {
+	tests := []struct {
+		name string
+		core *Core
+	}{
+		{"nil", nil},
+		{"zero value", &Core{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.core.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+		})
+	}
+}
+
+// trackingConn records whether it has been closed
+type trackingConn struct {
+	net.Conn
+	mu     sync.Mutex
+	closed bool
+}
+
+func (c *trackingConn) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+	return c.Conn.Close()
+}
+
+func TestNewClosesClientsOnError(t *testing.T) {
+	url := os.Getenv("COREGO_TEST_MONGODB_URL")
+	if url == "" {
+		t.Skip("COREGO_TEST_MONGODB_URL is not set")
+	}
+	t.Setenv("POSTGRES_CONNECTION_URL", "")
+
+	var (
+		mu    sync.Mutex
+		conns []*trackingConn
+	)
+	mongoDialer := database.DialFunc(func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := (&net.Dialer{}).DialContext(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		tc := &trackingConn{Conn: conn}
+		mu.Lock()
+		conns = append(conns, tc)
+		mu.Unlock()
+		return tc, nil
+	})
+	refused := errors.New("connection refused")
+
+	core, err := New(&Config{
+		Mongo: &database.MongoConfig{URL: url, Dialer: mongoDialer},
+		Postgres: &database.PostgresConfig{
+			URL: "postgres://user@192.0.2.1:5432/db",
+			Dialer: func(context.Context, string, string) (net.Conn, error) {
+				return nil, refused
+			},
+		},
+	})
+	if err == nil {
+		core.Close()
+		t.Fatal("New succeeded with an unreachable Postgres")
+	}
+	if core != nil {
+		t.Fatalf("New returned %v with an error", core)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(conns) == 0 {
+		t.Fatal("MongoDB was never dialed")
+	}
+	for i, conn := range conns {
+		conn.mu.Lock()
+		closed := conn.closed
+		conn.mu.Unlock()
+		if !closed {
+			t.Errorf("MongoDB connection %d left open after New failed", i)
+		}
+	}
+}
+
+func TestContextCancelledByClose(t *testing.T) {
+	t.Setenv("MONGODB_CONNECTION_URL", "")
+	t.Setenv("POSTGRES_CONNECTION_URL", "")
+
+	core, err := New(&Config{})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx := core.Context()
+
+	tests := []struct {
+		name     string
+		close    bool
+		wantDone bool
+	}{
+		{"open", false, false},
+		{"closed", true, true},
+		{"closed twice", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.close {
+				if err := core.Close(); err != nil {
+					t.Fatalf("Close: %v", err)
+				}
+			}
+			if done := ctx.Err() != nil; done != tt.wantDone {
+				t.Fatalf("Context done = %v, want %v", done, tt.wantDone)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		core    *Core
+		wantErr error
+	}{
+		{"nothing configured", &Core{config: &Config{}}, nil},
+		{"auth without databases", &Core{config: &Config{Auth: &auth.Config{}}}, ErrNoDatabase},
+		{"auth with postgres only", &Core{config: &Config{Auth: &auth.Config{}}, Postgres: &database.PostgresDB{}}, ErrAuthRequiresMongo},
+		{"auth initialized", &Core{config: &Config{Auth: &auth.Config{}}, Mongo: &database.MongoDB{}, Auth: &auth.Manager{}}, nil},
+		{"databases without auth", &Core{config: &Config{}, Mongo: &database.MongoDB{}, Postgres: &database.PostgresDB{}}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.core.Validate()
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Fatalf("Validate error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewValidates(t *testing.T) {
+	t.Setenv("MONGODB_CONNECTION_URL", "")
+	t.Setenv("POSTGRES_CONNECTION_URL", "")
+
+	core, err := New(&Config{Auth: &auth.Config{Secret: "test-secret"}})
+	if !errors.Is(err, ErrNoDatabase) || core != nil {
+		t.Fatalf("New = %v, %v, want nil, %v", core, err, ErrNoDatabase)
+	}
+}
+
+func TestMustNew(t *testing.T) {
+	t.Setenv("MONGODB_CONNECTION_URL", "")
+	t.Setenv("POSTGRES_CONNECTION_URL", "")
+
+	tests := []struct {
+		name      string
+		config    *Config
+		wantPanic string
+	}{
+		{"valid", &Config{}, ""},
+		{"nil config", nil, ""},
+		{"invalid", &Config{Auth: &auth.Config{Secret: "test-secret"}}, "corego: no database configured"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				got, _ := recover().(string)
+				if !strings.HasPrefix(got, tt.wantPanic) || (got == "") != (tt.wantPanic == "") {
+					t.Fatalf("panic = %q, want prefix %q", got, tt.wantPanic)
+				}
+			}()
+
+			core := MustNew(tt.config)
+			defer core.Close()
+		})
+	}
+}
@@ -1,11 +1,237 @@
 package database
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
 type MongoConfig struct {
 	URL			string
 	Database	string
+
+	// Discrete connection parts, used to build the URL when URL is empty
+	Host		string
+	Port		int
+	User		string
+	Password	string
+	Options		map[string]string
+
+	// Connection and pool tuning, zero values keep the driver defaults
+	ConnectTimeout	time.Duration	// default: 10s
+	MaxPoolSize		uint64
+	MinPoolSize		uint64
+	MaxConnIdleTime	time.Duration
+
+	// Optional: primary, primaryPreferred, secondary, secondaryPreferred or nearest
+	ReadPreference	string
+
+	// Optional: retry the initial connection with exponential backoff
+	Retry			*RetryConfig
+
+	// Optional: opens server connections instead of net.Dialer, e.g. through
+	// a tunnel, or to simulate failures in tests
+	Dialer			DialFunc
+
+	// Optional: Find, FindStream and FindPaginated return ErrCollectionNotFound
+	// for collections that don't exist instead of an empty result
+	StrictCollections	bool
+
+	// Optional: maximum concurrent operations; excess calls wait for a free slot
+	MaxConcurrentOps	int
+
+	// Optional: return every BSON integer as int64 in map results, instead of
+	// int32 or int64 depending on how the value was stored. Doubles stay float64.
+	NormalizeNumbers	bool
 }
 
 type PostgresConfig struct {
 	URL 		string
 	Database	string
-}
\ No newline at end of file
+
+	// Discrete connection parts, used to build the URL when URL is empty
+	Host		string
+	Port		int
+	User		string
+	Password	string
+	SSLMode		string
+	Options		map[string]string
+
+	// Optional read replicas; Query/QueryRows/QueryCSV are routed to them
+	ReadURLs	[]string
+
+	// Optional: PEM file paths for TLS. When any is set, TLS is required and
+	// the server certificate is verified against SSLRootCert (or system roots)
+	SSLRootCert	string
+	SSLCert		string
+	SSLKey		string
+
+	// Optional: maximum concurrent Query/Exec calls; excess calls wait for a free slot
+	MaxConcurrentOps	int
+
+	// Optional: how long a call waits for a pool connection, including opening one,
+	// before failing with ErrAcquireTimeout. Without it, waiting counts against the query timeout.
+	AcquireTimeout	time.Duration
+
+	// Optional: retry the initial connection with exponential backoff
+	Retry		*RetryConfig
+
+	// Optional: opens server connections instead of net.Dialer, e.g. through
+	// a tunnel, or to simulate failures in tests
+	Dialer		DialFunc
+}
+
+// ConnectionURL returns URL if set, with ${VAR} references expanded from the
+// environment, otherwise builds it from the discrete parts
+func (c *MongoConfig) ConnectionURL() (string, error) {
+	if c.URL != "" {
+		return expandEnv(c.URL)
+	}
+	if c.Host == "" {
+		return "", errors.New("mongodb connection URL or host is required")
+	}
+
+	u := &url.URL{
+		Scheme:   "mongodb",
+		Host:     joinHostPort(c.Host, c.Port),
+		Path:     "/",
+		RawQuery: encodeOptions(c.Options),
+	}
+	if c.User != "" {
+		u.User = url.UserPassword(c.User, c.Password)
+	}
+
+	return u.String(), nil
+}
+
+// ConnectionURL returns URL if set, with ${VAR} references expanded from the
+// environment, otherwise builds it from the discrete parts
+func (c *PostgresConfig) ConnectionURL() (string, error) {
+	if c.URL != "" {
+		return expandEnv(c.URL)
+	}
+	if c.Host == "" {
+		return "", errors.New("postgres connection URL or host is required")
+	}
+
+	options := make(map[string]string, len(c.Options)+1)
+	for k, v := range c.Options {
+		options[k] = v
+	}
+	if c.SSLMode != "" {
+		options["sslmode"] = c.SSLMode
+	}
+
+	u := &url.URL{
+		Scheme:   "postgres",
+		Host:     joinHostPort(c.Host, c.Port),
+		Path:     "/" + c.Database,
+		RawQuery: encodeOptions(options),
+	}
+	if c.User != "" {
+		u.User = url.UserPassword(c.User, c.Password)
+	}
+
+	return u.String(), nil
+}
+
+var envRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces ${VAR} with the value of the environment variable.
+// Bare $VAR is left alone, since passwords may contain "$". Values landing in
+// the user info are URL-escaped, so credentials may contain @, :, / and the like.
+func expandEnv(s string) (string, error) {
+	userinfoEnd := userinfoEnd(s)
+
+	var missing []string
+	var b strings.Builder
+	last := 0
+	for _, ref := range envRef.FindAllStringSubmatchIndex(s, -1) {
+		name := s[ref[2]:ref[3]]
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		if ref[0] < userinfoEnd {
+			value = strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+		}
+		b.WriteString(s[last:ref[0]])
+		b.WriteString(value)
+		last = ref[1]
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("unresolved environment variables in connection URL: %s", strings.Join(missing, ", "))
+	}
+	b.WriteString(s[last:])
+	return b.String(), nil
+}
+
+// userinfoEnd returns the index of the "@" ending the user info in a URL
+// template, or -1 if there is none
+func userinfoEnd(s string) int {
+	start := strings.Index(s, "://")
+	if start < 0 {
+		return -1
+	}
+	start += len("://")
+
+	authority := s[start:]
+	if end := strings.IndexAny(authority, "/?#"); end >= 0 {
+		authority = authority[:end]
+	}
+	at := strings.LastIndex(authority, "@")
+	if at < 0 {
+		return -1
+	}
+	return start + at
+}
+
+// resolveDatabase picks the database name: the configured one, else the path
+// of the connection URL (mongodb://host/myapp), else fallback
+func resolveDatabase(configured, connectionURL, fallback string) string {
+	if configured != "" {
+		return configured
+	}
+	if u, err := url.Parse(connectionURL); err == nil && u.Scheme != "" {
+		if name := strings.Trim(u.Path, "/"); name != "" {
+			return name
+		}
+	}
+	return fallback
+}
+
+// untilClosed returns a child of ctx that is also cancelled when closed is,
+// so long-running subscriptions stop when their connection is disconnected
+func untilClosed(ctx, closed context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(closed, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// Helper method
+func joinHostPort(host string, port int) string {
+	if port == 0 {
+		return host
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// Helper method
+func encodeOptions(options map[string]string) string {
+	values := url.Values{}
+	for k, v := range options {
+		values.Set(k, v)
+	}
+	// Encode sorts by key, so the result is deterministic
+	return values.Encode()
+}
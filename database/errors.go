@@ -0,0 +1,101 @@
+package database
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	// ErrNotFound is returned when no document or row matches the query
+	ErrNotFound = errors.New("not found")
+
+	// ErrNoRows is returned by QueryScalar and its typed variants for an empty result.
+	// It is ErrNotFound, so either can be checked.
+	ErrNoRows = ErrNotFound
+
+	// ErrCollectionNotFound is returned by Find methods in strict mode
+	// when the collection does not exist
+	ErrCollectionNotFound = errors.New("collection not found")
+
+	// ErrAcquireTimeout is returned when no Postgres pool connection frees up
+	// within PostgresConfig.AcquireTimeout, i.e. the pool is saturated
+	ErrAcquireTimeout = errors.New("timed out acquiring a connection from the pool")
+
+	// Constraint violations, see ConstraintError for details
+	ErrUniqueViolation     = errors.New("unique constraint violation")
+	ErrForeignKeyViolation = errors.New("foreign key constraint violation")
+	ErrNotNullViolation    = errors.New("not null constraint violation")
+)
+
+// ConstraintError describes a Postgres constraint violation.
+// It matches both its kind (errors.Is(err, ErrUniqueViolation)) and
+// the original *pgconn.PgError (errors.As).
+type ConstraintError struct {
+	Kind       error
+	Table      string
+	Constraint string
+	Column     string
+	Detail     string
+	PgErr      *pgconn.PgError
+}
+
+func (e *ConstraintError) Error() string {
+	if e.Constraint != "" {
+		return e.Kind.Error() + ": " + e.Constraint
+	}
+	return e.Kind.Error()
+}
+
+func (e *ConstraintError) Unwrap() []error {
+	return []error{e.Kind, e.PgErr}
+}
+
+// translateError converts known Postgres error codes into typed errors
+func translateError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	var kind error
+	switch pgErr.Code {
+	case "23505":
+		kind = ErrUniqueViolation
+	case "23503":
+		kind = ErrForeignKeyViolation
+	case "23502":
+		kind = ErrNotNullViolation
+	default:
+		return err
+	}
+
+	column := pgErr.ColumnName
+	if column == "" {
+		column = keyColumn(pgErr.Detail)
+	}
+
+	return &ConstraintError{
+		Kind:       kind,
+		Table:      pgErr.TableName,
+		Constraint: pgErr.ConstraintName,
+		Column:     column,
+		Detail:     pgErr.Detail,
+		PgErr:      pgErr,
+	}
+}
+
+// keyColumn extracts the column list from details like "Key (email)=(a@b.com) already exists."
+func keyColumn(detail string) string {
+	start := strings.Index(detail, "Key (")
+	if start == -1 {
+		return ""
+	}
+	rest := detail[start+len("Key ("):]
+	end := strings.Index(rest, ")=")
+	if end == -1 {
+		return ""
+	}
+	return rest[:end]
+}
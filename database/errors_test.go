@@ -0,0 +1,56 @@
+package database
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestTranslateError(t *testing.T) {
+	plain := errors.New("connection reset")
+
+	tests := []struct {
+		name           string
+		err            error
+		wantKind       error
+		wantConstraint string
+		wantColumn     string
+	}{
+		{"nil", nil, nil, "", ""},
+		{"not a postgres error", plain, nil, "", ""},
+		{"other code", &pgconn.PgError{Code: "42P01"}, nil, "", ""},
+		{"unique", &pgconn.PgError{Code: "23505", ConstraintName: "users_email_key", Detail: "Key (email)=(a@b.com) already exists."}, ErrUniqueViolation, "users_email_key", "email"},
+		{"unique composite key", &pgconn.PgError{Code: "23505", Detail: "Key (tenant, email)=(1, a@b.com) already exists."}, ErrUniqueViolation, "", "tenant, email"},
+		{"foreign key", &pgconn.PgError{Code: "23503", ConstraintName: "orders_user_id_fkey", Detail: "Key (user_id)=(7) is not present in table \"users\"."}, ErrForeignKeyViolation, "orders_user_id_fkey", "user_id"},
+		{"not null uses column name", &pgconn.PgError{Code: "23502", ColumnName: "name"}, ErrNotNullViolation, "", "name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := translateError(tt.err)
+
+			var constraintErr *ConstraintError
+			if !errors.As(err, &constraintErr) {
+				if tt.wantKind != nil {
+					t.Fatalf("translateError = %v, want a ConstraintError", err)
+				}
+				if err != tt.err {
+					t.Fatalf("translateError = %v, want the error unchanged", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, tt.wantKind) {
+				t.Fatalf("errors.Is(%v, %v) = false", err, tt.wantKind)
+			}
+			var pgErr *pgconn.PgError
+			if !errors.As(err, &pgErr) || pgErr != tt.err {
+				t.Fatal("the original PgError is not reachable with errors.As")
+			}
+			if constraintErr.Constraint != tt.wantConstraint || constraintErr.Column != tt.wantColumn {
+				t.Fatalf("constraint, column = %q, %q, want %q, %q", constraintErr.Constraint, constraintErr.Column, tt.wantConstraint, tt.wantColumn)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// allowedOperators are the comparison operators BuildFilter accepts
+var allowedOperators = map[string]bool{
+	"$eq":    true,
+	"$ne":    true,
+	"$gt":    true,
+	"$gte":   true,
+	"$lt":    true,
+	"$lte":   true,
+	"$in":    true,
+	"$nin":   true,
+	"$regex": true,
+}
+
+// BuildFilter builds a Mongo filter from field -> operator -> value conditions,
+// e.g. {"price": {"gt": 100}, "tags": {"in": []string{"go"}}}.
+// Operators may be written with or without the "$" prefix. Unknown operators
+// and field names starting with "$" are rejected, so the result is safe to
+// build from user input.
+func BuildFilter(conditions map[string]map[string]any) (bson.M, error) {
+	filter := bson.M{}
+
+	for field, ops := range conditions {
+		if field == "" || strings.HasPrefix(field, "$") {
+			return nil, fmt.Errorf("invalid filter field %q", field)
+		}
+
+		expr := bson.M{}
+		for op, value := range ops {
+			if !strings.HasPrefix(op, "$") {
+				op = "$" + op
+			}
+			if !allowedOperators[op] {
+				return nil, fmt.Errorf("unsupported filter operator %q on field %q", op, field)
+			}
+
+			switch op {
+			case "$in", "$nin":
+				kind := reflect.ValueOf(value).Kind()
+				if kind != reflect.Slice && kind != reflect.Array {
+					return nil, fmt.Errorf("operator %s on field %q requires a list", op, field)
+				}
+			case "$regex":
+				if _, ok := value.(string); !ok {
+					return nil, fmt.Errorf("operator $regex on field %q requires a string", field)
+				}
+			}
+
+			expr[op] = value
+		}
+
+		filter[field] = expr
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,42 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestBuildFilter(t *testing.T) {
+	tests := []struct {
+		name       string
+		conditions map[string]map[string]any
+		want       bson.M
+		wantErr    bool
+	}{
+		{"empty", nil, bson.M{}, false},
+		{"without prefix", map[string]map[string]any{"price": {"gt": 100}}, bson.M{"price": bson.M{"$gt": 100}}, false},
+		{"with prefix", map[string]map[string]any{"price": {"$lte": 5}}, bson.M{"price": bson.M{"$lte": 5}}, false},
+		{"range on one field", map[string]map[string]any{"age": {"gte": 18, "lt": 65}}, bson.M{"age": bson.M{"$gte": 18, "$lt": 65}}, false},
+		{"in with slice", map[string]map[string]any{"tags": {"in": []string{"go"}}}, bson.M{"tags": bson.M{"$in": []string{"go"}}}, false},
+		{"regex", map[string]map[string]any{"name": {"regex": "^ada"}}, bson.M{"name": bson.M{"$regex": "^ada"}}, false},
+		{"nested field", map[string]map[string]any{"address.city": {"eq": "Oslo"}}, bson.M{"address.city": bson.M{"$eq": "Oslo"}}, false},
+		{"unknown operator", map[string]map[string]any{"name": {"where": "1"}}, nil, true},
+		{"operator field", map[string]map[string]any{"$where": {"eq": "1"}}, nil, true},
+		{"empty field", map[string]map[string]any{"": {"eq": 1}}, nil, true},
+		{"in without list", map[string]map[string]any{"tags": {"nin": "go"}}, nil, true},
+		{"regex without string", map[string]map[string]any{"name": {"regex": 1}}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := BuildFilter(tt.conditions)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BuildFilter error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("BuildFilter = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
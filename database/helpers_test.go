@@ -0,0 +1,73 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// Database-backed tests run against these servers and are skipped when unset
+const (
+	testMongoURL    = "COREGO_TEST_MONGODB_URL"
+	testPostgresURL = "COREGO_TEST_POSTGRES_URL"
+)
+
+// newTestMongo connects to a fresh database that is dropped after the test.
+// config may be nil; its URL and Database are filled in.
+func newTestMongo(t *testing.T, config *MongoConfig) *MongoDB {
+	t.Helper()
+
+	url := os.Getenv(testMongoURL)
+	if url == "" {
+		t.Skipf("%s is not set", testMongoURL)
+	}
+	if config == nil {
+		config = &MongoConfig{}
+	}
+	config.URL = url
+	config.Database = fmt.Sprintf("corego_test_%d", time.Now().UnixNano())
+
+	db, err := NewMongoDB(config)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Database().Drop(context.Background())
+		db.Disconnect()
+	})
+	return db
+}
+
+// newTestPostgres connects to the test server. config may be nil; its URL is filled in.
+func newTestPostgres(t *testing.T, config *PostgresConfig) *PostgresDB {
+	t.Helper()
+
+	db, err := connectTestPostgres(t, config)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	return db
+}
+
+// connectTestPostgres is newTestPostgres returning the connection error
+func connectTestPostgres(t *testing.T, config *PostgresConfig) (*PostgresDB, error) {
+	t.Helper()
+
+	url := os.Getenv(testPostgresURL)
+	if url == "" {
+		t.Skipf("%s is not set", testPostgresURL)
+	}
+	if config == nil {
+		config = &PostgresConfig{}
+	}
+	config.URL = url
+
+	db, err := NewPostgresDB(config)
+	if err != nil {
+		return nil, err
+	}
+	t.Cleanup(func() { db.Disconnect() })
+	return db, nil
+}
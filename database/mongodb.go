@@ -2,57 +2,216 @@ package database
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
 	"time"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 type MongoDB struct {
 	client		*mongo.Client
 	config		*MongoConfig
+	sem			semaphore
+	pool		*poolCounter
+	closed		context.Context // cancelled by Disconnect, stops Watch
+	close		context.CancelFunc
 }
 
 func NewMongoDB(config *MongoConfig) (*MongoDB, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	clientOptions := options.Client().ApplyURI(config.URL)
-
-	client, err := mongo.Connect(ctx, clientOptions)
+	clientOptions, err := config.clientOptions()
 	if err != nil {
 		return nil, err
 	}
 
-	if err := client.Ping(ctx, nil); err != nil {
+	pool := newPoolCounter(clientOptions)
+	clientOptions.SetPoolMonitor(pool.monitor())
+
+	var client *mongo.Client
+	err = withRetry(config.Retry, func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), *clientOptions.ConnectTimeout)
+		defer cancel()
+
+		c, err := mongo.Connect(ctx, clientOptions)
+		if err != nil {
+			return err
+		}
+
+		if err := c.Ping(ctx, nil); err != nil {
+			c.Disconnect(context.Background())
+			return err
+		}
+
+		client = c
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	if config.Database == "" {
-		config.Database = "corego"
-	}
+	// clientOptions already checked the URL
+	connectionURL, _ := config.ConnectionURL()
+	config.Database = resolveDatabase(config.Database, connectionURL, "corego")
+
+	closed, cancel := context.WithCancel(context.Background())
 
 	return &MongoDB{
 		client: client,
 		config: config,
+		sem:    newSemaphore(config.MaxConcurrentOps),
+		pool:   pool,
+		closed: closed,
+		close:  cancel,
 	}, nil
 }
 
+// clientOptions builds driver options from the connection URL and pool settings
+func (c *MongoConfig) clientOptions() (*options.ClientOptions, error) {
+	connectionURL, err := c.ConnectionURL()
+	if err != nil {
+		return nil, err
+	}
+	if err := validateMongoURL(connectionURL); err != nil {
+		return nil, err
+	}
+
+	if c.ConnectTimeout < 0 || c.MaxConnIdleTime < 0 {
+		return nil, errors.New("mongodb timeouts must not be negative")
+	}
+	if c.MaxPoolSize > 0 && c.MinPoolSize > c.MaxPoolSize {
+		return nil, errors.New("mongodb MinPoolSize must not exceed MaxPoolSize")
+	}
+
+	// Every URI option (retryWrites, w, appName, tls, replicaSet, ...) is kept;
+	// config fields only override the ones they set explicitly
+	clientOptions := options.Client().ApplyURI(connectionURL)
+	if err := clientOptions.Validate(); err != nil {
+		// mongodb+srv:// URLs are resolved while parsing
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) {
+			return nil, fmt.Errorf("mongodb+srv lookup failed: %w", dnsErr)
+		}
+		return nil, fmt.Errorf("invalid MongoDB connection URL: %w", err)
+	}
+	if c.ConnectTimeout > 0 || clientOptions.ConnectTimeout == nil {
+		clientOptions.SetConnectTimeout(c.connectTimeout())
+	}
+	if c.MaxPoolSize > 0 {
+		clientOptions.SetMaxPoolSize(c.MaxPoolSize)
+	}
+	if c.MinPoolSize > 0 {
+		clientOptions.SetMinPoolSize(c.MinPoolSize)
+	}
+	if c.MaxConnIdleTime > 0 {
+		clientOptions.SetMaxConnIdleTime(c.MaxConnIdleTime)
+	}
+	if c.Dialer != nil {
+		clientOptions.SetDialer(c.Dialer)
+	}
+	if c.ReadPreference != "" {
+		mode, err := readpref.ModeFromString(c.ReadPreference)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mongodb read preference %q", c.ReadPreference)
+		}
+		rp, err := readpref.New(mode)
+		if err != nil {
+			return nil, err
+		}
+		clientOptions.SetReadPreference(rp)
+	}
+
+	return clientOptions, nil
+}
+
+// validateMongoURL checks the scheme and host before the driver sees the URL,
+// so a typo fails with a clear message instead of a driver parse error.
+// The URL itself is left out of the error since it may contain a password.
+func validateMongoURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return errors.New("invalid MongoDB connection URL: malformed URL")
+	}
+	if u.Scheme != "mongodb" && u.Scheme != "mongodb+srv" {
+		return fmt.Errorf("invalid MongoDB connection URL: scheme must be mongodb:// or mongodb+srv://, got %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return errors.New("invalid MongoDB connection URL: missing host")
+	}
+	return nil
+}
+
+// connectTimeout returns the configured connect timeout or the 10 second default
+func (c *MongoConfig) connectTimeout() time.Duration {
+	if c.ConnectTimeout == 0 {
+		return 10 * time.Second
+	}
+	return c.ConnectTimeout
+}
+
 func (m *MongoDB) GetClient() *mongo.Client {
 	return m.client
 }
 
+// Database returns the handle for the configured database, for advanced driver usage
+func (m *MongoDB) Database() *mongo.Database {
+	return m.client.Database(m.config.Database)
+}
+
+// ForTenant returns a view bound to another database that shares this
+// client and its connection pool. Don't call Disconnect on the view, it
+// would close the shared client.
+func (m *MongoDB) ForTenant(database string) *MongoDB {
+	config := *m.config
+	if database != "" {
+		config.Database = database
+	}
+
+	return &MongoDB{
+		client: m.client,
+		config: &config,
+		sem:    m.sem, // tenants share the client, and so its limit
+		pool:   m.pool,
+		closed: m.closed,
+		close:  m.close,
+	}
+}
+
 func (m *MongoDB) Disconnect() error {
+	m.close()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	return m.client.Disconnect(ctx)
 }
 
-func (m *MongoDB) InsertOne(collection string, document any) (string, error) {
+// Ping checks that the server is reachable
+func (m *MongoDB) Ping() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	return m.client.Ping(ctx, nil)
+}
+
+func (m *MongoDB) InsertOne(collection string, document any) (string, error) {
+	return m.InsertOneContext(context.Background(), collection, document)
+}
+
+// InsertOneContext is like InsertOne but runs under ctx
+func (m *MongoDB) InsertOneContext(ctx context.Context, collection string, document any) (string, error) {
+	if err := m.sem.acquire(ctx); err != nil {
+		return "", err
+	}
+	defer m.sem.release()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
 	db := m.client.Database(m.config.Database)
 	result, err := db.Collection(collection).InsertOne(ctx, document)
 	if err != nil {
@@ -68,15 +227,82 @@ func (m *MongoDB) InsertOne(collection string, document any) (string, error) {
 }
 
 func (m *MongoDB) FindOne(collection string, filter any, result any) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	return m.FindOneContext(context.Background(), collection, filter, result)
+}
+
+// FindOneContext is like FindOne but runs under ctx
+func (m *MongoDB) FindOneContext(ctx context.Context, collection string, filter any, result any) error {
+	if err := m.sem.acquire(ctx); err != nil {
+		return err
+	}
+	defer m.sem.release()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	db := m.client.Database(m.config.Database)
 	return db.Collection(collection).FindOne(ctx, filter).Decode(result)
 }
 
+// WithTransaction runs fn in a multi-document transaction, committing if fn
+// returns nil and aborting otherwise. Pass the ctx given to fn to the
+// ...Context methods (including auth's, e.g. SignupContext) so they take part
+// in the transaction. fn may be retried on transient errors, so it must be
+// safe to run more than once. Transactions require a replica set or sharded
+// cluster.
+func (m *MongoDB) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	session, err := m.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(context.Background())
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (any, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
+// Exists reports whether any document matches filter.
+// Only _id is fetched and nothing is decoded.
+func (m *MongoDB) Exists(collection string, filter any) (bool, error) {
+	return m.ExistsContext(context.Background(), collection, filter)
+}
+
+// ExistsContext is like Exists but runs under ctx
+func (m *MongoDB) ExistsContext(ctx context.Context, collection string, filter any) (bool, error) {
+	if err := m.sem.acquire(ctx); err != nil {
+		return false, err
+	}
+	defer m.sem.release()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	db := m.client.Database(m.config.Database)
+	opts := options.FindOne().SetProjection(bson.M{"_id": 1})
+	err := db.Collection(collection).FindOne(ctx, filter, opts).Err()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 func (m *MongoDB) DeleteOne(collection string, filter any) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	return m.DeleteOneContext(context.Background(), collection, filter)
+}
+
+// DeleteOneContext is like DeleteOne but runs under ctx
+func (m *MongoDB) DeleteOneContext(ctx context.Context, collection string, filter any) error {
+	if err := m.sem.acquire(ctx); err != nil {
+		return err
+	}
+	defer m.sem.release()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	db := m.client.Database(m.config.Database)
@@ -85,7 +311,17 @@ func (m *MongoDB) DeleteOne(collection string, filter any) error {
 }
 
 func (m *MongoDB) DeleteMany(collection string, filter any) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	return m.DeleteManyContext(context.Background(), collection, filter)
+}
+
+// DeleteManyContext is like DeleteMany but runs under ctx
+func (m *MongoDB) DeleteManyContext(ctx context.Context, collection string, filter any) error {
+	if err := m.sem.acquire(ctx); err != nil {
+		return err
+	}
+	defer m.sem.release()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	db := m.client.Database(m.config.Database)
@@ -94,7 +330,17 @@ func (m *MongoDB) DeleteMany(collection string, filter any) error {
 }
 
 func (m *MongoDB) UpdateOne(collection string, filter any, update any) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	return m.UpdateOneContext(context.Background(), collection, filter, update)
+}
+
+// UpdateOneContext is like UpdateOne but runs under ctx
+func (m *MongoDB) UpdateOneContext(ctx context.Context, collection string, filter any, update any) error {
+	if err := m.sem.acquire(ctx); err != nil {
+		return err
+	}
+	defer m.sem.release()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	db := m.client.Database(m.config.Database)
@@ -103,7 +349,17 @@ func (m *MongoDB) UpdateOne(collection string, filter any, update any) error {
 }
 
 func (m *MongoDB) UpdateMany(collection string, filter, update any) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	return m.UpdateManyContext(context.Background(), collection, filter, update)
+}
+
+// UpdateManyContext is like UpdateMany but runs under ctx
+func (m *MongoDB) UpdateManyContext(ctx context.Context, collection string, filter, update any) error {
+	if err := m.sem.acquire(ctx); err != nil {
+		return err
+	}
+	defer m.sem.release()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	db := m.client.Database(m.config.Database)
@@ -111,12 +367,349 @@ func (m *MongoDB) UpdateMany(collection string, filter, update any) error {
 	return err
 }
 
-func (m *MongoDB) Find(collection string, filter any) ([]map[string]any, error) {
+// UpsertMany inserts or updates documents in one unordered bulk write, matching
+// existing documents by keyField. Fields of a matched document are overwritten
+// with $set, others are kept. Every document must have keyField; an index on
+// it keeps large batches fast. It reports how many documents were inserted and
+// how many existing ones matched, whether or not their values changed.
+func (m *MongoDB) UpsertMany(collection, keyField string, documents []map[string]any) (inserted, matched int64, err error) {
+	if len(documents) == 0 {
+		return 0, 0, nil
+	}
+
+	models := make([]mongo.WriteModel, 0, len(documents))
+	for i, doc := range documents {
+		key, ok := doc[keyField]
+		if !ok {
+			return 0, 0, fmt.Errorf("document %d has no %q field", i, keyField)
+		}
+
+		fields := make(bson.M, len(doc))
+		for k, v := range doc {
+			if k != "_id" {
+				fields[k] = v
+			}
+		}
+
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{keyField: key}).
+			SetUpdate(bson.M{"$set": fields}).
+			SetUpsert(true))
+	}
+
+	if err := m.sem.acquire(context.Background()); err != nil {
+		return 0, 0, err
+	}
+	defer m.sem.release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	db := m.client.Database(m.config.Database)
+	result, err := db.Collection(collection).BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if result != nil {
+		inserted, matched = result.UpsertedCount, result.MatchedCount
+	}
+	return inserted, matched, err
+}
+
+// Set updates the first document matching filter, setting the given fields.
+// It wraps fields in $set, so no update operators are needed.
+func (m *MongoDB) Set(collection string, filter map[string]any, fields map[string]any) error {
+	if len(fields) == 0 {
+		return errors.New("no fields to set")
+	}
+	return m.UpdateOne(collection, filter, bson.M{"$set": fields})
+}
+
+// Inc increments numeric fields of the first document matching filter,
+// e.g. {"views": 1}. Negative amounts decrement.
+func (m *MongoDB) Inc(collection string, filter map[string]any, amounts map[string]any) error {
+	if len(amounts) == 0 {
+		return errors.New("no fields to increment")
+	}
+	return m.UpdateOne(collection, filter, bson.M{"$inc": amounts})
+}
+
+// Unset removes fields from the first document matching filter
+func (m *MongoDB) Unset(collection string, filter map[string]any, fields ...string) error {
+	if len(fields) == 0 {
+		return errors.New("no fields to unset")
+	}
+	unset := bson.M{}
+	for _, field := range fields {
+		unset[field] = ""
+	}
+	return m.UpdateOne(collection, filter, bson.M{"$unset": unset})
+}
+
+// FindOneAndUpdate atomically updates a single document and returns it.
+// When returnNew is true the updated document is returned, otherwise the original.
+func (m *MongoDB) FindOneAndUpdate(collection string, filter, update any, returnNew bool) (map[string]any, error) {
+	return m.FindOneAndUpdateContext(context.Background(), collection, filter, update, returnNew)
+}
+
+// FindOneAndUpdateContext is like FindOneAndUpdate but runs under ctx
+func (m *MongoDB) FindOneAndUpdateContext(ctx context.Context, collection string, filter, update any, returnNew bool) (map[string]any, error) {
+	if err := m.sem.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer m.sem.release()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+	if returnNew {
+		opts.SetReturnDocument(options.After)
+	}
+
+	db := m.client.Database(m.config.Database)
+	var result map[string]any
+	err := db.Collection(collection).FindOneAndUpdate(ctx, filter, update, opts).Decode(&result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	m.normalizeDoc(result)
+	return result, nil
+}
+
+// FindOneAndDelete atomically removes the first document matching filter in
+// sort order and returns it, e.g. to pop an item from a queue. A nil sort
+// takes any matching document. It returns ErrNotFound when nothing matches.
+func (m *MongoDB) FindOneAndDelete(collection string, filter, sort any) (map[string]any, error) {
+	return m.FindOneAndDeleteContext(context.Background(), collection, filter, sort)
+}
+
+// FindOneAndDeleteContext is like FindOneAndDelete but runs under ctx
+func (m *MongoDB) FindOneAndDeleteContext(ctx context.Context, collection string, filter, sort any) (map[string]any, error) {
+	if err := m.sem.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer m.sem.release()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if filter == nil {
+		filter = bson.M{}
+	}
+	opts := options.FindOneAndDelete()
+	if sort != nil {
+		opts.SetSort(sort)
+	}
+
+	db := m.client.Database(m.config.Database)
+	var result map[string]any
+	err := db.Collection(collection).FindOneAndDelete(ctx, filter, opts).Decode(&result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	m.normalizeDoc(result)
+	return result, nil
+}
+
+// Find returns all documents matching filter. opts are passed to the driver,
+// e.g. options.Find().SetCollation(...) for a case-insensitive match.
+func (m *MongoDB) Find(collection string, filter any, opts ...*options.FindOptions) ([]map[string]any, error) {
+	return m.FindContext(context.Background(), collection, filter, opts...)
+}
+
+// FindContext is like Find but runs under ctx
+func (m *MongoDB) FindContext(ctx context.Context, collection string, filter any, opts ...*options.FindOptions) ([]map[string]any, error) {
+	if err := m.sem.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer m.sem.release()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := m.checkCollection(ctx, collection); err != nil {
+		return nil, err
+	}
+
+	db := m.client.Database(m.config.Database)
+	cursor, err := db.Collection(collection).Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []map[string]any
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	m.normalizeDocs(results)
+	return results, nil
+}
+
+// FindPaginated returns one page of documents matching filter, ordered by sort
+// (nil keeps natural order). page is 1-based; page < 1 and pageSize < 1 fall
+// back to 1 and 20.
+func (m *MongoDB) FindPaginated(collection string, filter, sort any, page, pageSize int) (*Page[map[string]any], error) {
+	return FindPage[map[string]any](m, collection, filter, sort, page, pageSize)
+}
+
+// FindPage is FindPaginated decoding each document into T, e.g. a struct.
+// It is a function because Go methods can't have type parameters.
+func FindPage[T any](m *MongoDB, collection string, filter, sort any, page, pageSize int) (*Page[T], error) {
+	if err := m.sem.acquire(context.Background()); err != nil {
+		return nil, err
+	}
+	defer m.sem.release()
+
+	page, pageSize = normalizePage(page, pageSize)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := m.checkCollection(ctx, collection); err != nil {
+		return nil, err
+	}
+
+	if filter == nil {
+		filter = bson.M{}
+	}
+
+	coll := m.client.Database(m.config.Database).Collection(collection)
+	total, err := coll.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	findOpts := options.Find().
+		SetSkip(int64((page - 1) * pageSize)).
+		SetLimit(int64(pageSize))
+	if sort != nil {
+		findOpts.SetSort(sort)
+	}
+
+	cursor, err := coll.Find(ctx, filter, findOpts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var items []T
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+	if docs, ok := any(items).([]map[string]any); ok {
+		m.normalizeDocs(docs)
+	}
+
+	return NewPage(items, total, page, pageSize), nil
+}
+
+// FindPageFaceted is FindPaginated in a single round trip: one aggregation with
+// a $facet stage returns the page and the total together. It is usually faster
+// on remote servers; on large collections the count still scans every match.
+func (m *MongoDB) FindPageFaceted(collection string, filter, sort any, page, pageSize int) (*Page[map[string]any], error) {
+	if err := m.sem.acquire(context.Background()); err != nil {
+		return nil, err
+	}
+	defer m.sem.release()
+
+	page, pageSize = normalizePage(page, pageSize)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := m.checkCollection(ctx, collection); err != nil {
+		return nil, err
+	}
+
+	if filter == nil {
+		filter = bson.M{}
+	}
+
+	pipeline := []bson.M{{"$match": filter}}
+	if sort != nil {
+		pipeline = append(pipeline, bson.M{"$sort": sort})
+	}
+	pipeline = append(pipeline, bson.M{"$facet": bson.M{
+		"items": []bson.M{
+			{"$skip": (page - 1) * pageSize},
+			{"$limit": pageSize},
+		},
+		"total": []bson.M{{"$count": "count"}},
+	}})
+
+	cursor, err := m.client.Database(m.config.Database).Collection(collection).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var result struct {
+		Items []map[string]any `bson:"items"`
+		Total []struct {
+			Count int64 `bson:"count"`
+		} `bson:"total"`
+	}
+	if cursor.Next(ctx) {
+		if err := cursor.Decode(&result); err != nil {
+			return nil, err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	var total int64
+	if len(result.Total) > 0 {
+		total = result.Total[0].Count
+	}
+
+	m.normalizeDocs(result.Items)
+	return NewPage(result.Items, total, page, pageSize), nil
+}
+
+// TextSearch runs a $text query and returns up to limit documents ordered by
+// relevance, each with its "score". If the collection has no text index yet,
+// one over all string fields is created and the query retried. To choose the
+// searched fields, and keep the index build out of the first search, call
+// EnsureTextIndex at startup.
+func (m *MongoDB) TextSearch(collection, query string, limit int64) ([]map[string]any, error) {
+	results, err := m.textSearch(collection, query, limit)
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) && serverErr.HasErrorCode(27) { // 27: IndexNotFound
+		if err := m.EnsureTextIndex(collection); err != nil {
+			return nil, err
+		}
+		results, err = m.textSearch(collection, query, limit)
+	}
+	return results, err
+}
+
+// Helper method
+func (m *MongoDB) textSearch(collection, query string, limit int64) ([]map[string]any, error) {
+	if err := m.sem.acquire(context.Background()); err != nil {
+		return nil, err
+	}
+	defer m.sem.release()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	score := bson.M{"score": bson.M{"$meta": "textScore"}}
+	findOpts := options.Find().SetProjection(score).SetSort(score)
+	if limit > 0 {
+		findOpts.SetLimit(limit)
+	}
+
 	db := m.client.Database(m.config.Database)
-	cursor, err := db.Collection(collection).Find(ctx, filter)
+	cursor, err := db.Collection(collection).Find(ctx, bson.M{"$text": bson.M{"$search": query}}, findOpts)
 	if err != nil {
 		return nil, err
 	}
@@ -127,9 +720,323 @@ func (m *MongoDB) Find(collection string, filter any) ([]map[string]any, error)
 		return nil, err
 	}
 
+	m.normalizeDocs(results)
 	return results, nil
 }
 
+// EnsureTextIndex creates the text index TextSearch uses, over fields or, when
+// none are given, over all string fields. A collection has at most one text
+// index, so it does nothing if one already exists.
+func (m *MongoDB) EnsureTextIndex(collection string, fields ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	db := m.client.Database(m.config.Database)
+	specs, err := db.Collection(collection).Indexes().ListSpecifications(ctx)
+	var cmdErr mongo.CommandError
+	if err != nil && !(errors.As(err, &cmdErr) && cmdErr.Code == 26) { // 26: NamespaceNotFound
+		return err
+	}
+	for _, spec := range specs {
+		var keys bson.D
+		if err := bson.Unmarshal(spec.KeysDocument, &keys); err != nil {
+			return err
+		}
+		for _, key := range keys {
+			// Text indexes are stored as {_fts: "text", _ftsx: 1}
+			if key.Key == "_fts" {
+				return nil
+			}
+		}
+	}
+
+	keys := bson.D{{Key: "$**", Value: "text"}}
+	if len(fields) > 0 {
+		keys = make(bson.D, len(fields))
+		for i, field := range fields {
+			keys[i] = bson.E{Key: field, Value: "text"}
+		}
+	}
+	return m.EnsureIndex(collection, mongo.IndexModel{Keys: keys})
+}
+
+// checkCollection returns ErrCollectionNotFound in strict mode when the
+// collection doesn't exist. It is a no-op in the default lenient mode.
+func (m *MongoDB) checkCollection(ctx context.Context, collection string) error {
+	if !m.config.StrictCollections {
+		return nil
+	}
+	// listCollections isn't allowed inside transactions
+	if session := mongo.SessionFromContext(ctx); session != nil {
+		return nil
+	}
+
+	db := m.client.Database(m.config.Database)
+	names, err := db.ListCollectionNames(ctx, bson.M{"name": collection})
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("%w: %s", ErrCollectionNotFound, collection)
+	}
+	return nil
+}
+
+// AggregateOptions tunes long-running aggregations
+type AggregateOptions struct {
+	// AllowDiskUse lets stages exceed the in-memory limit by writing temp files
+	AllowDiskUse bool
+	// MaxTime is the server-side time limit. It also replaces the default
+	// 5 second client timeout, so long pipelines aren't cut off early.
+	MaxTime time.Duration
+}
+
+// Aggregate runs an aggregation pipeline and returns all resulting documents
+func (m *MongoDB) Aggregate(collection string, pipeline any, opts ...AggregateOptions) ([]map[string]any, error) {
+	if err := m.sem.acquire(context.Background()); err != nil {
+		return nil, err
+	}
+	defer m.sem.release()
+
+	timeout := 5 * time.Second
+	aggOpts := options.Aggregate()
+	if len(opts) > 0 {
+		if opts[0].AllowDiskUse {
+			aggOpts.SetAllowDiskUse(true)
+		}
+		if opts[0].MaxTime > 0 {
+			aggOpts.SetMaxTime(opts[0].MaxTime)
+			// Leave room for the server to report the MaxTime error itself
+			timeout = opts[0].MaxTime + 5*time.Second
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	db := m.client.Database(m.config.Database)
+	cursor, err := db.Collection(collection).Aggregate(ctx, pipeline, aggOpts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []map[string]any
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	m.normalizeDocs(results)
+	return results, nil
+}
+
+// Sample returns up to size random documents of collection matching filter,
+// e.g. for a "featured item". A nil filter matches every document; fewer
+// than size documents are returned when fewer match.
+func (m *MongoDB) Sample(collection string, filter any, size int64) ([]map[string]any, error) {
+	if size <= 0 {
+		return nil, errors.New("sample size must be positive")
+	}
+	if filter == nil {
+		filter = bson.M{}
+	}
+
+	if err := m.sem.acquire(context.Background()); err != nil {
+		return nil, err
+	}
+	defer m.sem.release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := m.checkCollection(ctx, collection); err != nil {
+		return nil, err
+	}
+
+	pipeline := []bson.M{
+		{"$match": filter},
+		{"$sample": bson.M{"size": size}},
+	}
+
+	cursor, err := m.client.Database(m.config.Database).Collection(collection).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	results := make([]map[string]any, 0)
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	m.normalizeDocs(results)
+	return results, nil
+}
+
+// LookupJoin returns documents of collection matching filter, each with an
+// array of the foreignCollection documents whose foreignField equals its
+// localField, stored under as. A nil filter matches every document.
+func (m *MongoDB) LookupJoin(collection, localField, foreignCollection, foreignField, as string, filter any) ([]map[string]any, error) {
+	if filter == nil {
+		filter = bson.M{}
+	}
+
+	pipeline := []bson.M{
+		{"$match": filter},
+		{"$lookup": bson.M{
+			"from":         foreignCollection,
+			"localField":   localField,
+			"foreignField": foreignField,
+			"as":           as,
+		}},
+	}
+	return m.Aggregate(collection, pipeline)
+}
+
+// FindStream iterates matching documents one at a time instead of loading them all.
+// Iteration stops at the first error returned by fn, which is returned to the caller.
+// With MaxConcurrentOps, a slot is held only while reading from the server, not
+// while fn runs, so fn may call other methods.
+func (m *MongoDB) FindStream(ctx context.Context, collection string, filter any, fn func(doc map[string]any) error) error {
+	cursor, err := m.openStream(ctx, collection, filter)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for {
+		doc, err := m.nextStreamDoc(ctx, cursor)
+		if err != nil || doc == nil {
+			return err
+		}
+		if err := fn(doc); err != nil {
+			return err
+		}
+	}
+}
+
+// openStream runs the FindStream query while holding a slot
+func (m *MongoDB) openStream(ctx context.Context, collection string, filter any) (*mongo.Cursor, error) {
+	if err := m.sem.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer m.sem.release()
+
+	if err := m.checkCollection(ctx, collection); err != nil {
+		return nil, err
+	}
+
+	db := m.client.Database(m.config.Database)
+	return db.Collection(collection).Find(ctx, filter)
+}
+
+// nextStreamDoc reads the next document while holding a slot; it returns
+// nil without an error once the cursor is exhausted
+func (m *MongoDB) nextStreamDoc(ctx context.Context, cursor *mongo.Cursor) (map[string]any, error) {
+	if err := m.sem.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer m.sem.release()
+
+	if !cursor.Next(ctx) {
+		return nil, cursor.Err()
+	}
+
+	var doc map[string]any
+	if err := cursor.Decode(&doc); err != nil {
+		return nil, err
+	}
+	m.normalizeDoc(doc)
+	return doc, nil
+}
+
+// EnsureIndex creates the index if it doesn't exist yet (creation is idempotent)
+func (m *MongoDB) EnsureIndex(collection string, model mongo.IndexModel) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	db := m.client.Database(m.config.Database)
+	_, err := db.Collection(collection).Indexes().CreateOne(ctx, model)
+	return err
+}
+
+// EnsureTTLIndex makes documents expire ttl after the time stored in field.
+// Use a ttl of 0 when field already holds the exact expiry time.
+func (m *MongoDB) EnsureTTLIndex(collection, field string, ttl time.Duration) error {
+	return m.EnsureIndex(collection, mongo.IndexModel{
+		Keys:    bson.D{{Key: field, Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(ttl.Seconds())),
+	})
+}
+
 func (m *MongoDB) Collection(name string) *mongo.Collection {
 	return m.client.Database(m.config.Database).Collection(name)
-}
\ No newline at end of file
+}
+
+// Watch subscribes to a change stream on the collection and calls handler for
+// every event until ctx is cancelled or the client is disconnected. Change
+// streams require a replica set or sharded cluster; standalone servers return
+// an error on the first call. If the stream drops with a resumable error, it
+// is reopened from the last seen resume token; other errors are returned.
+func (m *MongoDB) Watch(ctx context.Context, collection string, pipeline []bson.M, handler func(event map[string]any)) error {
+	if pipeline == nil {
+		pipeline = []bson.M{}
+	}
+
+	ctx, cancel := untilClosed(ctx, m.closed)
+	defer cancel()
+
+	coll := m.client.Database(m.config.Database).Collection(collection)
+	var resumeToken bson.Raw
+
+	for {
+		opts := options.ChangeStream()
+		if resumeToken != nil {
+			opts.SetResumeAfter(resumeToken)
+		}
+
+		stream, err := coll.Watch(ctx, pipeline, opts)
+		if err == nil {
+			for stream.Next(ctx) {
+				var event map[string]any
+				if err := stream.Decode(&event); err != nil {
+					stream.Close(context.Background())
+					return err
+				}
+				resumeToken = stream.ResumeToken()
+				handler(event)
+			}
+
+			err = stream.Err()
+			stream.Close(context.Background())
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil && (resumeToken == nil || !isResumableStreamError(err)) {
+			return err
+		}
+
+		// Stream dropped, reconnect from the last resume token
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// isResumableStreamError reports whether a change stream that failed with err
+// can be reopened from its resume token, e.g. after a network error or an
+// election. Errors such as a lost oplog position are final.
+func isResumableStreamError(err error) bool {
+	if mongo.IsNetworkError(err) {
+		return true
+	}
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) {
+		return serverErr.HasErrorLabel("ResumableChangeStreamError")
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package database
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// normalizeDocs converts int32 values to int64 in place, including nested
+// documents and arrays, when MongoConfig.NormalizeNumbers is set
+func (m *MongoDB) normalizeDocs(docs []map[string]any) {
+	if !m.config.NormalizeNumbers {
+		return
+	}
+	for _, doc := range docs {
+		normalizeMap(doc)
+	}
+}
+
+// normalizeDoc is normalizeDocs for a single document
+func (m *MongoDB) normalizeDoc(doc map[string]any) {
+	if m.config.NormalizeNumbers {
+		normalizeMap(doc)
+	}
+}
+
+func normalizeMap(doc map[string]any) {
+	for key, value := range doc {
+		doc[key] = normalizeValue(value)
+	}
+}
+
+func normalizeValue(value any) any {
+	switch v := value.(type) {
+	case int32:
+		return int64(v)
+	case map[string]any:
+		normalizeMap(v)
+	case primitive.M:
+		normalizeMap(v)
+	case primitive.D:
+		for i := range v {
+			v[i].Value = normalizeValue(v[i].Value)
+		}
+	case primitive.A:
+		for i := range v {
+			v[i] = normalizeValue(v[i])
+		}
+	case []any:
+		for i := range v {
+			v[i] = normalizeValue(v[i])
+		}
+	}
+	return value
+}
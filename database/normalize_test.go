@@ -0,0 +1,54 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestNormalizeDocs(t *testing.T) {
+	doc := func() map[string]any {
+		return map[string]any{
+			"count":   int32(3),
+			"big":     int64(1) << 40,
+			"price":   1.5,
+			"name":    "ada",
+			"nested":  primitive.M{"n": int32(1), "deeper": map[string]any{"n": int32(2)}},
+			"ordered": primitive.D{{Key: "n", Value: int32(4)}},
+			"list":    primitive.A{int32(5), primitive.M{"n": int32(6)}},
+			"slice":   []any{int32(7)},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		normalize bool
+		want      map[string]any
+	}{
+		{"disabled", false, doc()},
+		{"enabled", true, map[string]any{
+			"count":   int64(3),
+			"big":     int64(1) << 40,
+			"price":   1.5,
+			"name":    "ada",
+			"nested":  primitive.M{"n": int64(1), "deeper": map[string]any{"n": int64(2)}},
+			"ordered": primitive.D{{Key: "n", Value: int64(4)}},
+			"list":    primitive.A{int64(5), primitive.M{"n": int64(6)}},
+			"slice":   []any{int64(7)},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &MongoDB{config: &MongoConfig{NormalizeNumbers: tt.normalize}}
+			docs := []map[string]any{doc()}
+			m.normalizeDocs(docs)
+
+			// %#v includes the types, so int32 and int64 differ
+			if got, want := fmt.Sprintf("%#v", docs[0]), fmt.Sprintf("%#v", tt.want); got != want {
+				t.Fatalf("document = %s, want %s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// maxNotifyPayload is Postgres' NOTIFY payload limit in the default configuration
+const maxNotifyPayload = 8000
+
+var channelName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,62}$`)
+
+// Notify publishes payload on channel with pg_notify.
+// Payloads must be shorter than 8000 bytes; send an ID and look up larger data instead.
+func (p *PostgresDB) Notify(channel, payload string) error {
+	if !channelName.MatchString(channel) {
+		return fmt.Errorf("invalid channel name %q", channel)
+	}
+	if len(payload) >= maxNotifyPayload {
+		return errors.New("notify payload must be shorter than 8000 bytes")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := p.acquire(ctx, p.pool)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	_, err = conn.Exec(ctx, "SELECT pg_notify($1, $2)", channel, payload)
+	return err
+}
+
+// Listen subscribes to channel and calls handler for every notification until
+// ctx is cancelled or the database is disconnected. It holds a dedicated
+// connection outside the pool while running.
+func (p *PostgresDB) Listen(ctx context.Context, channel string, handler func(payload string)) error {
+	if !channelName.MatchString(channel) {
+		return fmt.Errorf("invalid channel name %q", channel)
+	}
+
+	ctx, cancel := untilClosed(ctx, p.closed)
+	defer cancel()
+
+	conn, err := p.acquire(ctx, p.pool)
+	if err != nil {
+		return err
+	}
+	// Take the connection out of the pool so it isn't reused while LISTENing
+	pgConn := conn.Hijack()
+	defer pgConn.Close(context.Background())
+
+	if _, err := pgConn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		return err
+	}
+
+	for {
+		notification, err := pgConn.WaitForNotification(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		handler(notification.Payload)
+	}
+}
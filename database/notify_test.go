@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNotifyValidation(t *testing.T) {
+	// Invalid arguments are rejected before a connection is needed
+	db := &PostgresDB{}
+
+	tests := []struct {
+		name    string
+		channel string
+		payload string
+	}{
+		{"empty channel", "", "x"},
+		{"channel with a space", "user events", "x"},
+		{"channel starting with a digit", "1events", "x"},
+		{"channel with a quote", `events"; DROP TABLE users; --`, "x"},
+		{"channel too long", strings.Repeat("c", 64), "x"},
+		{"payload too long", "events", strings.Repeat("p", maxNotifyPayload)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := db.Notify(tt.channel, tt.payload); err == nil {
+				t.Fatal("Notify succeeded, want an error")
+			}
+			if len(tt.payload) < maxNotifyPayload {
+				if err := db.Listen(t.Context(), tt.channel, func(string) {}); err == nil {
+					t.Fatal("Listen succeeded, want an error")
+				}
+			}
+		})
+	}
+}
+
+func TestNotifyListen(t *testing.T) {
+	db := newTestPostgres(t, nil)
+
+	tests := []struct {
+		name     string
+		channel  string
+		payloads []string
+	}{
+		{"single payload", "corego_single", []string{"42"}},
+		{"in order", "corego_ordered", []string{"1", "2", "3"}},
+		{"mixed case channel", "CoreGo_Mixed", []string{"quoted"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(t.Context(), 10*time.Second)
+			defer cancel()
+
+			const probe = "subscribed?"
+			ready := make(chan struct{}, 1)
+			received := make(chan string, len(tt.payloads))
+			done := make(chan error, 1)
+			go func() {
+				done <- db.Listen(ctx, tt.channel, func(payload string) {
+					if payload == probe {
+						select {
+						case ready <- struct{}{}:
+						default:
+						}
+						return
+					}
+					received <- payload
+				})
+			}()
+
+			// Notifications sent before LISTEN are lost, so probe until one arrives
+		subscribe:
+			for {
+				if err := db.Notify(tt.channel, probe); err != nil {
+					t.Fatalf("Notify: %v", err)
+				}
+				select {
+				case <-ready:
+					break subscribe
+				case <-time.After(100 * time.Millisecond):
+				case <-ctx.Done():
+					t.Fatal("listener never subscribed")
+				}
+			}
+
+			var got []string
+			for _, payload := range tt.payloads {
+				if err := db.Notify(tt.channel, payload); err != nil {
+					t.Fatalf("Notify: %v", err)
+				}
+				select {
+				case p := <-received:
+					got = append(got, p)
+				case <-ctx.Done():
+					t.Fatal("no notification received")
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tt.payloads, ",") {
+				t.Fatalf("received %v, want %v", got, tt.payloads)
+			}
+
+			cancel()
+			if err := <-done; err != context.Canceled {
+				t.Fatalf("Listen error = %v, want %v", err, context.Canceled)
+			}
+		})
+	}
+}
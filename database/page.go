@@ -0,0 +1,41 @@
+package database
+
+// Page is a single page of results plus the totals needed to render pagination
+type Page[T any] struct {
+	Items      []T   `json:"items"`
+	Total      int64 `json:"total"`
+	Page       int   `json:"page"`
+	PageSize   int   `json:"page_size"`
+	TotalPages int   `json:"total_pages"`
+}
+
+// NewPage wraps items and computes TotalPages, rounding up for a partial last page
+func NewPage[T any](items []T, total int64, page, pageSize int) *Page[T] {
+	if items == nil {
+		items = []T{}
+	}
+
+	totalPages := 0
+	if pageSize > 0 {
+		totalPages = int((total + int64(pageSize) - 1) / int64(pageSize))
+	}
+
+	return &Page[T]{
+		Items:      items,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: totalPages,
+	}
+}
+
+// normalizePage applies the defaults for page (1) and page size (20)
+func normalizePage(page, pageSize int) (int, int) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 20
+	}
+	return page, pageSize
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNewPage(t *testing.T) {
+	tests := []struct {
+		name     string
+		items    []int
+		total    int64
+		page     int
+		pageSize int
+		want     string
+	}{
+		{"empty", nil, 0, 1, 20, `{"items":[],"total":0,"page":1,"page_size":20,"total_pages":0}`},
+		{"exact pages", []int{1, 2}, 4, 1, 2, `{"items":[1,2],"total":4,"page":1,"page_size":2,"total_pages":2}`},
+		{"partial last page", []int{5}, 5, 3, 2, `{"items":[5],"total":5,"page":3,"page_size":2,"total_pages":3}`},
+		{"past the end", nil, 5, 9, 2, `{"items":[],"total":5,"page":9,"page_size":2,"total_pages":3}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := json.Marshal(NewPage(tt.items, tt.total, tt.page, tt.pageSize))
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			if string(body) != tt.want {
+				t.Fatalf("NewPage = %s, want %s", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizePage(t *testing.T) {
+	tests := []struct {
+		page, pageSize         int
+		wantPage, wantPageSize int
+	}{
+		{0, 0, 1, 20},
+		{-3, -1, 1, 20},
+		{2, 50, 2, 50},
+	}
+
+	for _, tt := range tests {
+		page, pageSize := normalizePage(tt.page, tt.pageSize)
+		if page != tt.wantPage || pageSize != tt.wantPageSize {
+			t.Errorf("normalizePage(%d, %d) = %d, %d, want %d, %d", tt.page, tt.pageSize, page, pageSize, tt.wantPage, tt.wantPageSize)
+		}
+	}
+}
@@ -2,34 +2,149 @@ package database
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type PostgresDB struct {
-	pool   *pgxpool.Pool
-	config *PostgresConfig
+	pool      *pgxpool.Pool
+	readPools []*pgxpool.Pool
+	next      atomic.Uint64
+	config    *PostgresConfig
+	sem       semaphore
+	closed    context.Context // cancelled by Disconnect, stops Listen
+	close     context.CancelFunc
 }
 
 func NewPostgresDB(config *PostgresConfig) (*PostgresDB, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	connectionURL, err := config.ConnectionURL()
+	if err != nil {
+		return nil, err
+	}
 
-	pool, err := pgxpool.New(ctx, config.URL)
+	tlsConfig, err := config.TLSConfig()
 	if err != nil {
 		return nil, err
 	}
 
-	if err := pool.Ping(ctx); err != nil {
+	// An explicit Database wins over the one in the URL, as for MongoDB; with
+	// neither, Postgres defaults to the user name
+	pool, err := connectPool(connectionURL, config.Database, tlsConfig, config.Dialer, config.Retry)
+	if err != nil {
 		return nil, err
 	}
 
-	return &PostgresDB{
+	db := &PostgresDB{
 		pool:   pool,
 		config: config,
-	}, nil
+		sem:    newSemaphore(config.MaxConcurrentOps),
+	}
+	db.closed, db.close = context.WithCancel(context.Background())
+
+	for _, readURL := range config.ReadURLs {
+		readURL, err := expandEnv(readURL)
+		if err != nil {
+			db.Disconnect()
+			return nil, err
+		}
+		readPool, err := connectPool(readURL, config.Database, tlsConfig, config.Dialer, config.Retry)
+		if err != nil {
+			db.Disconnect()
+			return nil, err
+		}
+		db.readPools = append(db.readPools, readPool)
+	}
+
+	return db, nil
+}
+
+// Helper method
+func connectPool(connectionURL, database string, tlsConfig *tls.Config, dialer DialFunc, retry *RetryConfig) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(connectionURL)
+	if err != nil {
+		return nil, err
+	}
+	if database != "" {
+		poolConfig.ConnConfig.Database = database
+	}
+	if tlsConfig != nil {
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.ServerName = poolConfig.ConnConfig.Host
+		poolConfig.ConnConfig.TLSConfig = tlsConfig
+		// sslmode=prefer/allow add plaintext fallbacks; certificates mean TLS only
+		poolConfig.ConnConfig.Fallbacks = nil
+	}
+	if dialer != nil {
+		poolConfig.ConnConfig.DialFunc = pgconn.DialFunc(dialer)
+	}
+
+	var pool *pgxpool.Pool
+	err = withRetry(retry, func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		p, err := newPool(ctx, poolConfig)
+		if err != nil {
+			return err
+		}
+		pool = p
+		return nil
+	})
+	return pool, err
+}
+
+// Helper method
+func newPool(ctx context.Context, poolConfig *pgxpool.Config) (*pgxpool.Pool, error) {
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	return pool, nil
+}
+
+// readPool picks a replica round-robin, falling back to the primary
+func (p *PostgresDB) readPool() *pgxpool.Pool {
+	if len(p.readPools) == 0 {
+		return p.pool
+	}
+	n := p.next.Add(1)
+	return p.readPools[n%uint64(len(p.readPools))]
+}
+
+// acquire takes a connection from pool, waiting at most AcquireTimeout
+func (p *PostgresDB) acquire(ctx context.Context, pool *pgxpool.Pool) (*pgxpool.Conn, error) {
+	if p.config.AcquireTimeout <= 0 {
+		return pool.Acquire(ctx)
+	}
+
+	acquireCtx, cancel := context.WithTimeout(ctx, p.config.AcquireTimeout)
+	defer cancel()
+
+	conn, err := pool.Acquire(acquireCtx)
+	if err != nil && ctx.Err() == nil && errors.Is(acquireCtx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("%w after %s", ErrAcquireTimeout, p.config.AcquireTimeout)
+	}
+	return conn, err
 }
 
 func (p *PostgresDB) GetPool() *pgxpool.Pool {
@@ -37,40 +152,344 @@ func (p *PostgresDB) GetPool() *pgxpool.Pool {
 }
 
 func (p *PostgresDB) Disconnect() error {
+	p.close()
 	p.pool.Close()
+	for _, readPool := range p.readPools {
+		readPool.Close()
+	}
 	// pool.Close doesn't return an error
 	return nil
 }
 
+// Ping checks that the server is reachable
+func (p *PostgresDB) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := p.pool.Ping(ctx); err != nil {
+		return err
+	}
+	for _, readPool := range p.readPools {
+		if err := readPool.Ping(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Query executes any SQL and returns results as []map[string]any
 // Works for SELECT, INSERT...RETURNING, UPDATE...RETURNING, etc.
+// When read replicas are configured, Query runs on a replica; use QueryPrimary for writes
 func (p *PostgresDB) Query(sql string, args ...any) ([]map[string]any, error) {
+	return p.query(p.readPool(), 5*time.Second, sql, args...)
+}
+
+// QueryPrimary is Query always routed to the primary
+// Use it for INSERT/UPDATE...RETURNING and for read-after-write consistency
+func (p *PostgresDB) QueryPrimary(sql string, args ...any) ([]map[string]any, error) {
+	return p.query(p.pool, 5*time.Second, sql, args...)
+}
+
+// QueryWithTimeout is Query with a per-call timeout instead of the 5 second default
+// Use it for long-running reports without changing the default for every query
+func (p *PostgresDB) QueryWithTimeout(timeout time.Duration, sql string, args ...any) ([]map[string]any, error) {
+	return p.query(p.readPool(), timeout, sql, args...)
+}
+
+// Helper method
+func (p *PostgresDB) query(pool *pgxpool.Pool, timeout time.Duration, sql string, args ...any) ([]map[string]any, error) {
+	if err := p.sem.acquire(context.Background()); err != nil {
+		return nil, err
+	}
+	defer p.sem.release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	conn, err := p.acquire(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	rows, err := conn.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	results, err := rowsToMaps(rows)
+	return results, translateError(err)
+}
+
+// QueryJSON is Query returning the rows as a JSON array of objects, e.g. for
+// caching or publishing to a queue. UUIDs are written as strings, json and
+// jsonb columns as nested JSON, numerics as numbers and bytea as base64.
+func (p *PostgresDB) QueryJSON(sql string, args ...any) ([]byte, error) {
+	rows, err := p.query(p.readPool(), 5*time.Second, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		for column, value := range row {
+			row[column] = jsonValue(value)
+		}
+	}
+	return json.Marshal(rows)
+}
+
+// QueryScalar returns the first column of the first row, e.g. for
+// SELECT COUNT(*) or INSERT ... RETURNING id. It always runs on the primary,
+// so it sees its own writes. It returns ErrNoRows when the query returns no rows.
+func (p *PostgresDB) QueryScalar(sql string, args ...any) (any, error) {
+	if err := p.sem.acquire(context.Background()); err != nil {
+		return nil, err
+	}
+	defer p.sem.release()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	rows, err := p.pool.Query(ctx, sql, args...)
+	conn, err := p.acquire(ctx, p.pool)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	rows, err := conn.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, translateError(err)
+		}
+		return nil, ErrNoRows
+	}
+
+	values, err := rows.Values()
 	if err != nil {
 		return nil, err
 	}
+	if len(values) == 0 {
+		return nil, errors.New("query returned no columns")
+	}
+	return values[0], nil
+}
+
+// QueryInt is QueryScalar for integer results, including whole numeric
+// values such as SUM(integer)
+func (p *PostgresDB) QueryInt(sql string, args ...any) (int64, error) {
+	value, err := p.QueryScalar(sql, args...)
+	if err != nil {
+		return 0, err
+	}
+	return toInt64(value)
+}
+
+// toInt64 converts the integer types pgx decodes into to int64
+func toInt64(value any) (int64, error) {
+	switch n := value.(type) {
+	case int8:
+		return int64(n), nil
+	case int16:
+		return int64(n), nil
+	case int32:
+		return int64(n), nil
+	case int64:
+		return n, nil
+	case int:
+		return int64(n), nil
+	case uint8:
+		return int64(n), nil
+	case uint16:
+		return int64(n), nil
+	case uint32:
+		return int64(n), nil
+	case uint64:
+		if n > math.MaxInt64 {
+			return 0, fmt.Errorf("query returned %d, which overflows int64", n)
+		}
+		return int64(n), nil
+	case pgtype.Numeric:
+		if !n.Valid || n.NaN || n.InfinityModifier != pgtype.Finite {
+			return 0, errors.New("query returned a numeric that is not a finite number")
+		}
+		i, err := n.Int64Value()
+		if err != nil {
+			return 0, fmt.Errorf("query returned a numeric that is not an int64: %w", err)
+		}
+		return i.Int64, nil
+	}
+	return 0, fmt.Errorf("query returned %T, not an integer", value)
+}
+
+// QueryString is QueryScalar for text results
+func (p *PostgresDB) QueryString(sql string, args ...any) (string, error) {
+	value, err := p.QueryScalar(sql, args...)
+	if err != nil {
+		return "", err
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("query returned %T, not a string", value)
+	}
+	return s, nil
+}
+
+// QueryRows executes SQL and returns column names and raw row values in SELECT order
+// Useful when column order matters, e.g. CSV export
+func (p *PostgresDB) QueryRows(sql string, args ...any) ([]string, [][]any, error) {
+	if err := p.sem.acquire(context.Background()); err != nil {
+		return nil, nil, err
+	}
+	defer p.sem.release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := p.acquire(ctx, p.readPool())
+	if err != nil {
+		return nil, nil, err
+	}
+	defer conn.Release()
+
+	rows, err := conn.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, nil, translateError(err)
+	}
 	defer rows.Close()
 
-	return rowsToMaps(rows)
+	columns := columnNames(rows)
+	results := make([][]any, 0)
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return nil, nil, err
+		}
+		results = append(results, values)
+	}
+
+	return columns, results, translateError(rows.Err())
+}
+
+// QueryCSV streams query results to w as CSV with a header row
+// Rows are written one at a time, so large result sets are not buffered.
+// The whole export must finish within 5 seconds; use QueryCSVContext for longer ones.
+func (p *PostgresDB) QueryCSV(w io.Writer, sql string, args ...any) error {
+	return p.queryCSV(context.Background(), 5*time.Second, w, sql, args...)
+}
+
+// QueryCSVContext is like QueryCSV but runs until ctx is done, with no
+// timeout of its own
+func (p *PostgresDB) QueryCSVContext(ctx context.Context, w io.Writer, sql string, args ...any) error {
+	return p.queryCSV(ctx, 0, w, sql, args...)
+}
+
+// queryCSV runs the export under ctx, bounded by timeout unless it is 0
+func (p *PostgresDB) queryCSV(ctx context.Context, timeout time.Duration, w io.Writer, sql string, args ...any) error {
+	if err := p.sem.acquire(ctx); err != nil {
+		return err
+	}
+	defer p.sem.release()
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	conn, err := p.acquire(ctx, p.readPool())
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	rows, err := conn.Query(ctx, sql, args...)
+	if err != nil {
+		return translateError(err)
+	}
+	defer rows.Close()
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(columnNames(rows)); err != nil {
+		return err
+	}
+
+	record := make([]string, len(rows.FieldDescriptions()))
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return err
+		}
+		for i, v := range values {
+			record[i] = csvValue(v)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return translateError(err)
+	}
+
+	writer.Flush()
+	return writer.Error()
 }
 
 // Exec executes SQL without returning rows (INSERT, UPDATE, DELETE)
 // Returns number of affected rows
 func (p *PostgresDB) Exec(sql string, args ...any) (int64, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	return p.ExecWithTimeout(5*time.Second, sql, args...)
+}
+
+// ExecWithTimeout is Exec with a per-call timeout instead of the 5 second default
+func (p *PostgresDB) ExecWithTimeout(timeout time.Duration, sql string, args ...any) (int64, error) {
+	if err := p.sem.acquire(context.Background()); err != nil {
+		return 0, err
+	}
+	defer p.sem.release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	result, err := p.pool.Exec(ctx, sql, args...)
+	conn, err := p.acquire(ctx, p.pool)
 	if err != nil {
 		return 0, err
 	}
+	defer conn.Release()
+
+	result, err := conn.Exec(ctx, sql, args...)
+	if err != nil {
+		return 0, translateError(err)
+	}
 
 	return result.RowsAffected(), nil
 }
 
+// SafeOrderBy builds an ORDER BY clause from user input.
+// column must be in allowed; direction is "asc" or "desc" (case-insensitive, default asc).
+func SafeOrderBy(column, direction string, allowed []string) (string, error) {
+	if !slices.Contains(allowed, column) {
+		return "", fmt.Errorf("sorting by %q is not allowed", column)
+	}
+
+	dir := strings.ToUpper(direction)
+	if dir == "" {
+		dir = "ASC"
+	}
+	if dir != "ASC" && dir != "DESC" {
+		return "", fmt.Errorf("invalid sort direction %q", direction)
+	}
+
+	ident := pgx.Identifier(strings.Split(column, "."))
+	return "ORDER BY " + ident.Sanitize() + " " + dir, nil
+}
+
 // Helper method
 func rowsToMaps(rows pgx.Rows) ([]map[string]any, error) {
 	results := make([]map[string]any, 0)
@@ -90,4 +509,58 @@ func rowsToMaps(rows pgx.Rows) ([]map[string]any, error) {
 	}
 
 	return results, rows.Err()
-}
\ No newline at end of file
+}
+
+// Helper method
+func columnNames(rows pgx.Rows) []string {
+	fields := rows.FieldDescriptions()
+	columns := make([]string, len(fields))
+	for i, fd := range fields {
+		columns[i] = fd.Name
+	}
+	return columns
+}
+
+// jsonValue converts values pgx returns that don't marshal to useful JSON
+func jsonValue(v any) any {
+	switch val := v.(type) {
+	case [16]byte:
+		// uuid
+		return fmt.Sprintf("%x-%x-%x-%x-%x", val[0:4], val[4:6], val[6:8], val[8:10], val[10:16])
+	case float32:
+		return jsonFloat(float64(val))
+	case float64:
+		return jsonFloat(val)
+	case []any:
+		for i := range val {
+			val[i] = jsonValue(val[i])
+		}
+		return val
+	default:
+		return v
+	}
+}
+
+// jsonFloat keeps NaN and infinities, which JSON numbers can't hold, as strings
+func jsonFloat(f float64) any {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Sprint(f)
+	}
+	return f
+}
+
+// Helper method
+func csvValue(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case []byte:
+		return string(val)
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(val)
+	}
+}
@@ -0,0 +1,454 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"net"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestToInt64(t *testing.T) {
+	numeric := func(i int64, exp int32) pgtype.Numeric {
+		return pgtype.Numeric{Int: big.NewInt(i), Exp: exp, Valid: true}
+	}
+
+	tests := []struct {
+		name    string
+		value   any
+		want    int64
+		wantErr bool
+	}{
+		{"int16", int16(-7), -7, false},
+		{"int32", int32(42), 42, false},
+		{"int64", int64(math.MaxInt64), math.MaxInt64, false},
+		{"uint32 oid", uint32(4294967295), 4294967295, false},
+		{"uint64", uint64(9), 9, false},
+		{"uint64 overflow", uint64(math.MaxUint64), 0, true},
+		{"whole numeric", numeric(15, 0), 15, false},
+		{"numeric with exponent", numeric(12, 3), 12000, false},
+		{"numeric with zero fraction", numeric(1500, -2), 15, false},
+		{"fractional numeric", numeric(150, -2), 0, true},
+		{"numeric overflow", pgtype.Numeric{Int: new(big.Int).Lsh(big.NewInt(1), 64), Valid: true}, 0, true},
+		{"numeric NaN", pgtype.Numeric{NaN: true, Valid: true}, 0, true},
+		{"numeric infinity", pgtype.Numeric{InfinityModifier: pgtype.Infinity, Valid: true}, 0, true},
+		{"null", nil, 0, true},
+		{"string", "12", 0, true},
+		{"float", 1.0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := toInt64(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("toInt64 error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("toInt64 = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryInt(t *testing.T) {
+	db := newTestPostgres(t, nil)
+
+	tests := []struct {
+		name string
+		sql  string
+		want int64
+	}{
+		{"count", "SELECT COUNT(*) FROM generate_series(1, 3)", 3},
+		{"sum is numeric", "SELECT SUM(n::bigint) FROM (VALUES (1), (2), (3)) AS t(n)", 6},
+		{"oid is unsigned", "SELECT 'pg_class'::regclass::oid", 1259},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := db.QueryInt(tt.sql)
+			if err != nil {
+				t.Fatalf("QueryInt: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("QueryInt = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryCSV(t *testing.T) {
+	db := newTestPostgres(t, nil)
+
+	tests := []struct {
+		name string
+		sql  string
+		args []any
+		want string
+	}{
+		{"columns in select order", "SELECT 2 AS b, 1 AS a", nil, "b,a\n2,1\n"},
+		{"quoted values", `SELECT 'x, "y"' AS s`, nil, "s\n\"x, \"\"y\"\"\"\n"},
+		{"null is empty", "SELECT NULL::text AS s, 1 AS n", nil, "s,n\n,1\n"},
+		{"args", "SELECT $1::text AS s", []any{"hi"}, "s\nhi\n"},
+		{"no rows", "SELECT 1 AS n WHERE false", nil, "n\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := db.QueryCSV(&buf, tt.sql, tt.args...); err != nil {
+				t.Fatalf("QueryCSV: %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Fatalf("CSV = %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryCSVContext(t *testing.T) {
+	db := newTestPostgres(t, nil)
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		sql     string
+		want    string
+		wantErr error
+	}{
+		{"rows", context.Background(), "SELECT n, 'row ' || n AS label FROM generate_series(1, 2) AS n", "n,label\n1,row 1\n2,row 2\n", nil},
+		{"longer than the QueryCSV timeout", context.Background(), "SELECT 1 AS n FROM pg_sleep(5.5)", "n\n1\n", nil},
+		{"cancelled", cancelled, "SELECT 1 AS n", "", context.Canceled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := db.QueryCSVContext(tt.ctx, &buf, tt.sql)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("QueryCSVContext error = %v, want %v", err, tt.wantErr)
+			}
+			if buf.String() != tt.want {
+				t.Fatalf("CSV = %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+
+	start := time.Now()
+	if err := db.QueryCSV(&bytes.Buffer{}, "SELECT 1 FROM pg_sleep(6)"); err == nil {
+		t.Fatal("QueryCSV outlived its 5 second timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 6*time.Second {
+		t.Fatalf("QueryCSV returned after %v", elapsed)
+	}
+}
+
+func TestQueryRows(t *testing.T) {
+	db := newTestPostgres(t, nil)
+
+	tests := []struct {
+		name        string
+		sql         string
+		args        []any
+		wantColumns []string
+		wantRows    [][]any
+	}{
+		{"columns in select order", "SELECT 3 AS z, 'b' AS a, true AS m", nil, []string{"z", "a", "m"}, [][]any{{int32(3), "b", true}}},
+		{"several rows", "SELECT n AS id FROM generate_series(1, 3) AS n ORDER BY n", nil, []string{"id"}, [][]any{{int32(1)}, {int32(2)}, {int32(3)}}},
+		{"no rows", "SELECT 1 AS id WHERE $1", []any{false}, []string{"id"}, [][]any{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			columns, rows, err := db.QueryRows(tt.sql, tt.args...)
+			if err != nil {
+				t.Fatalf("QueryRows: %v", err)
+			}
+			if !slices.Equal(columns, tt.wantColumns) {
+				t.Fatalf("columns = %v, want %v", columns, tt.wantColumns)
+			}
+			if !reflect.DeepEqual(rows, tt.wantRows) {
+				t.Fatalf("rows = %v, want %v", rows, tt.wantRows)
+			}
+		})
+	}
+}
+
+func TestReadPool(t *testing.T) {
+	// Pools connect lazily, so these never reach a server
+	newPool := func() *pgxpool.Pool {
+		pool, err := pgxpool.New(context.Background(), "postgres://user@127.0.0.1:1/db")
+		if err != nil {
+			t.Fatalf("pgxpool.New: %v", err)
+		}
+		t.Cleanup(pool.Close)
+		return pool
+	}
+	primary := newPool()
+
+	tests := []struct {
+		name     string
+		replicas int
+	}{
+		{"no replicas", 0},
+		{"one replica", 1},
+		{"three replicas", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &PostgresDB{pool: primary}
+			for i := 0; i < tt.replicas; i++ {
+				db.readPools = append(db.readPools, newPool())
+			}
+
+			counts := make(map[*pgxpool.Pool]int)
+			for i := 0; i < 3*max(tt.replicas, 1); i++ {
+				counts[db.readPool()]++
+			}
+
+			if tt.replicas == 0 {
+				if counts[primary] != 3 {
+					t.Fatalf("reads went to %d pools, want only the primary", len(counts))
+				}
+				return
+			}
+			if counts[primary] != 0 {
+				t.Fatal("a read went to the primary despite replicas")
+			}
+			for _, replica := range db.readPools {
+				if counts[replica] != 3 {
+					t.Fatalf("replica got %d of %d reads, want an even round-robin", counts[replica], 3*tt.replicas)
+				}
+			}
+		})
+	}
+}
+
+func TestSafeOrderBy(t *testing.T) {
+	allowed := []string{"name", "created_at", "users.email", `we"ird`}
+
+	tests := []struct {
+		name      string
+		column    string
+		direction string
+		want      string
+		wantErr   bool
+	}{
+		{"default direction", "name", "", `ORDER BY "name" ASC`, false},
+		{"desc any case", "created_at", "Desc", `ORDER BY "created_at" DESC`, false},
+		{"qualified column", "users.email", "asc", `ORDER BY "users"."email" ASC`, false},
+		{"quotes escaped", `we"ird`, "", `ORDER BY "we""ird" ASC`, false},
+		{"column not allowed", "password", "asc", "", true},
+		{"injection attempt", "name; DROP TABLE users", "", "", true},
+		{"bad direction", "name", "asc; DROP TABLE users", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SafeOrderBy(tt.column, tt.direction, allowed)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SafeOrderBy error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("SafeOrderBy = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	db := newTestPostgres(t, nil)
+
+	run := map[string]func(timeout time.Duration) error{
+		"QueryWithTimeout": func(timeout time.Duration) error {
+			_, err := db.QueryWithTimeout(timeout, "SELECT pg_sleep(0.3)")
+			return err
+		},
+		"ExecWithTimeout": func(timeout time.Duration) error {
+			_, err := db.ExecWithTimeout(timeout, "SELECT pg_sleep(0.3)")
+			return err
+		},
+	}
+
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		wantErr bool
+	}{
+		{"shorter than the query", 50 * time.Millisecond, true},
+		{"longer than the query", 5 * time.Second, false},
+	}
+
+	for method, call := range run {
+		for _, tt := range tests {
+			t.Run(method+"/"+tt.name, func(t *testing.T) {
+				start := time.Now()
+				err := call(tt.timeout)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("%s error = %v, wantErr %v", method, err, tt.wantErr)
+				}
+				if tt.wantErr && time.Since(start) >= 300*time.Millisecond {
+					t.Fatalf("%s returned after %v, want about %v", method, time.Since(start), tt.timeout)
+				}
+			})
+		}
+	}
+}
+
+func TestJSONValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		want  string
+	}{
+		{"uuid", [16]byte{0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0, 0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0}, `"12345678-9abc-def0-1234-56789abcdef0"`},
+		{"float", 1.5, `1.5`},
+		{"float32", float32(0.25), `0.25`},
+		{"NaN", math.NaN(), `"NaN"`},
+		{"infinity", math.Inf(1), `"+Inf"`},
+		{"array of uuids", []any{[16]byte{15: 1}, nil}, `["00000000-0000-0000-0000-000000000001",null]`},
+		{"bytea", []byte("hi"), `"aGk="`},
+		{"json", map[string]any{"a": 1}, `{"a":1}`},
+		{"numeric", pgtype.Numeric{Int: big.NewInt(1234), Exp: -2, Valid: true}, `12.34`},
+		{"null", nil, `null`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := json.Marshal(jsonValue(tt.value))
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			if string(body) != tt.want {
+				t.Fatalf("jsonValue = %s, want %s", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryJSON(t *testing.T) {
+	db := newTestPostgres(t, nil)
+
+	tests := []struct {
+		name string
+		sql  string
+		want string
+	}{
+		{"no rows", "SELECT 1 AS n WHERE false", `[]`},
+		{"scalars", "SELECT 1 AS n, 'a' AS s, true AS b, NULL AS z", `[{"b":true,"n":1,"s":"a","z":null}]`},
+		{"uuid", "SELECT '12345678-9abc-def0-1234-56789abcdef0'::uuid AS id", `[{"id":"12345678-9abc-def0-1234-56789abcdef0"}]`},
+		{"jsonb", `SELECT '{"a":[1,2]}'::jsonb AS doc`, `[{"doc":{"a":[1,2]}}]`},
+		{"numeric", "SELECT 12.34::numeric AS price", `[{"price":12.34}]`},
+		{"NaN", "SELECT 'NaN'::float8 AS f", `[{"f":"NaN"}]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := db.QueryJSON(tt.sql)
+			if err != nil {
+				t.Fatalf("QueryJSON: %v", err)
+			}
+			if string(body) != tt.want {
+				t.Fatalf("QueryJSON = %s, want %s", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestAcquireTimeout(t *testing.T) {
+	refused := errors.New("connection refused")
+
+	tests := []struct {
+		name           string
+		acquireTimeout time.Duration
+		ctxTimeout     time.Duration
+		dialErr        error
+		wantErr        error
+	}{
+		{"pool saturated", 20 * time.Millisecond, time.Second, nil, ErrAcquireTimeout},
+		{"caller deadline first", time.Second, 20 * time.Millisecond, nil, context.DeadlineExceeded},
+		{"no acquire timeout", 0, 20 * time.Millisecond, nil, context.DeadlineExceeded},
+		{"dial fails", time.Second, time.Second, refused, refused},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			poolConfig, err := pgxpool.ParseConfig("postgres://user@127.0.0.1:1/db?pool_max_conns=1")
+			if err != nil {
+				t.Fatalf("ParseConfig: %v", err)
+			}
+			// A dial that hangs stands in for a pool with no free connection
+			release := make(chan struct{})
+			poolConfig.ConnConfig.DialFunc = func(ctx context.Context, _, _ string) (net.Conn, error) {
+				if tt.dialErr != nil {
+					return nil, tt.dialErr
+				}
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case <-release:
+					return nil, refused
+				}
+			}
+			pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
+			if err != nil {
+				t.Fatalf("pgxpool.NewWithConfig: %v", err)
+			}
+			defer pool.Close()
+			defer close(release)
+
+			p := &PostgresDB{config: &PostgresConfig{AcquireTimeout: tt.acquireTimeout}, pool: pool}
+			ctx, cancel := context.WithTimeout(t.Context(), tt.ctxTimeout)
+			defer cancel()
+
+			conn, err := p.acquire(ctx, pool)
+			if err == nil {
+				conn.Release()
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("acquire error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != ErrAcquireTimeout && errors.Is(err, ErrAcquireTimeout) {
+				t.Fatalf("acquire error = %v, want no %v", err, ErrAcquireTimeout)
+			}
+		})
+	}
+}
+
+func TestAcquireTimeoutSaturated(t *testing.T) {
+	db := newTestPostgres(t, &PostgresConfig{AcquireTimeout: 50 * time.Millisecond})
+	pool := db.GetPool()
+
+	// Hold every connection so the query has to wait for one
+	var held []*pgxpool.Conn
+	for range pool.Config().MaxConns {
+		conn, err := pool.Acquire(t.Context())
+		if err != nil {
+			t.Fatalf("Acquire: %v", err)
+		}
+		held = append(held, conn)
+	}
+
+	if _, err := db.Query("SELECT 1"); !errors.Is(err, ErrAcquireTimeout) {
+		t.Fatalf("Query error = %v, want %v", err, ErrAcquireTimeout)
+	}
+
+	for _, conn := range held {
+		conn.Release()
+	}
+	if _, err := db.Query("SELECT 1"); err != nil {
+		t.Fatalf("Query after release: %v", err)
+	}
+}
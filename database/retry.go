@@ -0,0 +1,59 @@
+package database
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// RetryConfig controls connection retries at startup
+type RetryConfig struct {
+	Attempts     int           // total attempts, 0 or 1 means no retry
+	InitialDelay time.Duration // default: 500ms, doubled after each failure
+	MaxDelay     time.Duration // default: 30s
+}
+
+// DialFunc opens a network connection, like net.Dialer.DialContext
+type DialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// DialContext lets a DialFunc serve as the MongoDB driver's dialer
+func (f DialFunc) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return f(ctx, network, address)
+}
+
+// sleep waits between attempts; tests replace it to skip the delays
+var sleep = time.Sleep
+
+// withRetry calls connect until it succeeds or attempts run out,
+// sleeping with exponential backoff between attempts
+func withRetry(config *RetryConfig, connect func() error) error {
+	attempts, delay, maxDelay := 1, 500*time.Millisecond, 30*time.Second
+	if config != nil {
+		if config.Attempts > 1 {
+			attempts = config.Attempts
+		}
+		if config.InitialDelay > 0 {
+			delay = config.InitialDelay
+		}
+		if config.MaxDelay > 0 {
+			maxDelay = config.MaxDelay
+		}
+	}
+
+	delay = min(delay, maxDelay)
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = connect(); err == nil {
+			return nil
+		}
+		if attempt == attempts {
+			break
+		}
+
+		sleep(delay)
+		delay = min(delay*2, maxDelay)
+	}
+
+	return err
+}
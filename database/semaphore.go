@@ -0,0 +1,34 @@
+package database
+
+import "context"
+
+// semaphore bounds the number of concurrent operations. A nil semaphore
+// doesn't limit anything, so callers can use it unconditionally.
+type semaphore chan struct{}
+
+func newSemaphore(size int) semaphore {
+	if size <= 0 {
+		return nil
+	}
+	return make(semaphore, size)
+}
+
+// acquire blocks until a slot is free or ctx is done. The operation timeout
+// starts once the slot is acquired, so waiting for a slot doesn't eat into it.
+func (s semaphore) acquire(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	select {
+	case s <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s semaphore) release() {
+	if s != nil {
+		<-s
+	}
+}
@@ -0,0 +1,77 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestSemaphoreAcquire(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name    string
+		size    int
+		held    int
+		ctx     func() (context.Context, context.CancelFunc)
+		wantErr error
+	}{
+		{"unlimited", 0, 0, background, nil},
+		{"free slot", 2, 1, background, nil},
+		{"full until cancelled", 1, 1, func() (context.Context, context.CancelFunc) { return cancelled, func() {} }, context.Canceled},
+		{"full until deadline", 1, 1, func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), 20*time.Millisecond)
+		}, context.DeadlineExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sem := newSemaphore(tt.size)
+			for i := 0; i < tt.held; i++ {
+				if err := sem.acquire(context.Background()); err != nil {
+					t.Fatalf("hold slot: %v", err)
+				}
+			}
+
+			ctx, cancel := tt.ctx()
+			defer cancel()
+			if err := sem.acquire(ctx); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("acquire error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func background() (context.Context, context.CancelFunc) {
+	return context.Background(), func() {}
+}
+
+func TestFindStreamCallbackCanQuery(t *testing.T) {
+	db := newTestMongo(t, &MongoConfig{MaxConcurrentOps: 1})
+	for i := 0; i < 3; i++ {
+		if _, err := db.InsertOne("items", bson.M{"n": i}); err != nil {
+			t.Fatalf("InsertOne: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	seen := 0
+	err := db.FindStream(ctx, "items", bson.M{}, func(doc map[string]any) error {
+		// Needs the only slot; deadlocks if FindStream still holds it
+		_, err := db.FindOneAndUpdateContext(ctx, "items", bson.M{"_id": doc["_id"]}, bson.M{"$set": bson.M{"seen": true}}, true)
+		seen++
+		return err
+	})
+	if err != nil {
+		t.Fatalf("FindStream: %v", err)
+	}
+	if seen != 3 {
+		t.Fatalf("streamed %d documents, want 3", seen)
+	}
+}
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"sync/atomic"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PoolStats is a snapshot of connection pool usage
+type PoolStats struct {
+	Acquired int32 `json:"acquired"` // connections in use
+	Idle     int32 `json:"idle"`     // open connections waiting to be used
+	Total    int32 `json:"total"`    // open connections
+	Max      int32 `json:"max"`      // pool size limit
+}
+
+// Stat returns the primary pool's connection usage
+func (p *PostgresDB) Stat() PoolStats {
+	stat := p.pool.Stat()
+	return PoolStats{
+		Acquired: stat.AcquiredConns(),
+		Idle:     stat.IdleConns(),
+		Total:    stat.TotalConns(),
+		Max:      stat.MaxConns(),
+	}
+}
+
+// Stat returns connection usage summed over every server the client is
+// connected to. Max is the per-server pool size limit from the config or
+// the URL, 0 when unlimited.
+func (m *MongoDB) Stat() PoolStats {
+	total := m.pool.total.Load()
+	acquired := m.pool.acquired.Load()
+	return PoolStats{
+		Acquired: acquired,
+		Idle:     total - acquired,
+		Total:    total,
+		Max:      int32(m.pool.max),
+	}
+}
+
+// poolCounter tracks the driver's connection pool events, since the Mongo
+// driver has no pool stats API
+type poolCounter struct {
+	total    atomic.Int32
+	acquired atomic.Int32
+	max      uint64
+}
+
+// newPoolCounter takes the limit from the built client options, so a
+// maxPoolSize in the URL is reported as well as MongoConfig.MaxPoolSize
+func newPoolCounter(clientOptions *options.ClientOptions) *poolCounter {
+	pool := &poolCounter{max: 100} // driver default
+	if clientOptions.MaxPoolSize != nil {
+		pool.max = *clientOptions.MaxPoolSize
+	}
+	return pool
+}
+
+func (c *poolCounter) monitor() *event.PoolMonitor {
+	return &event.PoolMonitor{
+		Event: func(e *event.PoolEvent) {
+			switch e.Type {
+			case event.ConnectionCreated:
+				c.total.Add(1)
+			case event.ConnectionClosed:
+				c.total.Add(-1)
+			case event.GetSucceeded:
+				c.acquired.Add(1)
+			case event.ConnectionReturned:
+				c.acquired.Add(-1)
+			}
+		},
+	}
+}
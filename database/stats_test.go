@@ -0,0 +1,91 @@
+package database
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+func TestMongoPoolStats(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []string
+		want   PoolStats
+	}{
+		{"no connections", nil, PoolStats{Max: 100}},
+		{"created", []string{event.ConnectionCreated, event.ConnectionCreated}, PoolStats{Idle: 2, Total: 2, Max: 100}},
+		{"checked out", []string{event.ConnectionCreated, event.ConnectionCreated, event.GetSucceeded}, PoolStats{Acquired: 1, Idle: 1, Total: 2, Max: 100}},
+		{"returned", []string{event.ConnectionCreated, event.GetSucceeded, event.ConnectionReturned}, PoolStats{Idle: 1, Total: 1, Max: 100}},
+		{"closed", []string{event.ConnectionCreated, event.ConnectionCreated, event.ConnectionClosed}, PoolStats{Idle: 1, Total: 1, Max: 100}},
+		{"other events ignored", []string{event.ConnectionCreated, event.PoolCleared, event.GetFailed}, PoolStats{Idle: 1, Total: 1, Max: 100}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := &poolCounter{max: 100}
+			monitor := pool.monitor()
+			for _, typ := range tt.events {
+				monitor.Event(&event.PoolEvent{Type: typ})
+			}
+
+			if got := (&MongoDB{pool: pool}).Stat(); got != tt.want {
+				t.Fatalf("Stat = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMongoPoolMax(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *MongoConfig
+		want   uint64
+	}{
+		{"driver default", &MongoConfig{URL: "mongodb://localhost:27017"}, 100},
+		{"from URL", &MongoConfig{URL: "mongodb://localhost:27017/?maxPoolSize=20"}, 20},
+		{"config overrides URL", &MongoConfig{URL: "mongodb://localhost:27017/?maxPoolSize=20", MaxPoolSize: 50}, 50},
+		{"unlimited", &MongoConfig{URL: "mongodb://localhost:27017/?maxPoolSize=0"}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientOptions, err := tt.config.clientOptions()
+			if err != nil {
+				t.Fatalf("clientOptions: %v", err)
+			}
+			if got := newPoolCounter(clientOptions).max; got != tt.want {
+				t.Fatalf("max = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostgresPoolStats(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		want PoolStats
+	}{
+		{"max from URL", "postgres://user@127.0.0.1:1/db?pool_max_conns=7", PoolStats{Max: 7}},
+		// pgxpool defaults to the larger of 4 and the CPU count
+		{"default max", "postgres://user@127.0.0.1:1/db", PoolStats{Max: int32(max(4, runtime.NumCPU()))}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The pool connects lazily, so it never reaches a server
+			pool, err := pgxpool.New(context.Background(), tt.url)
+			if err != nil {
+				t.Fatalf("pgxpool.New: %v", err)
+			}
+			defer pool.Close()
+
+			if got := (&PostgresDB{pool: pool}).Stat(); got != tt.want {
+				t.Fatalf("Stat = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
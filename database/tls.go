@@ -0,0 +1,44 @@
+package database
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// TLSConfig builds a client TLS config from SSLRootCert, SSLCert and SSLKey.
+// It returns nil when none of them are set, leaving TLS to the URL's sslmode.
+func (c *PostgresConfig) TLSConfig() (*tls.Config, error) {
+	if c.SSLRootCert == "" && c.SSLCert == "" && c.SSLKey == "" {
+		return nil, nil
+	}
+	if (c.SSLCert == "") != (c.SSLKey == "") {
+		return nil, errors.New("postgres SSLCert and SSLKey must be set together")
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if c.SSLRootCert != "" {
+		pem, err := os.ReadFile(c.SSLRootCert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read postgres SSLRootCert: %w", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("postgres SSLRootCert %s contains no PEM certificates", c.SSLRootCert)
+		}
+		tlsConfig.RootCAs = roots
+	}
+
+	if c.SSLCert != "" {
+		cert, err := tls.LoadX509KeyPair(c.SSLCert, c.SSLKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load postgres client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
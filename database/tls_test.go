@@ -0,0 +1,106 @@
+package database
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCert writes a self-signed certificate and its key as PEM files
+// and returns their paths
+func writeTestCert(t *testing.T, dir, name string) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	writeFile(t, certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	writeFile(t, keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	return certFile, keyFile
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func TestPostgresTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	caFile, _ := writeTestCert(t, dir, "ca")
+	certFile, keyFile := writeTestCert(t, dir, "client")
+	_, otherKey := writeTestCert(t, dir, "other")
+	notPEM := filepath.Join(dir, "not.pem")
+	writeFile(t, notPEM, []byte("not a certificate"))
+	missing := filepath.Join(dir, "missing.pem")
+
+	tests := []struct {
+		name      string
+		config    PostgresConfig
+		wantNil   bool
+		wantRoots bool
+		wantCerts int
+		wantErr   bool
+	}{
+		{"unset", PostgresConfig{}, true, false, 0, false},
+		{"root only", PostgresConfig{SSLRootCert: caFile}, false, true, 0, false},
+		{"client only", PostgresConfig{SSLCert: certFile, SSLKey: keyFile}, false, false, 1, false},
+		{"all", PostgresConfig{SSLRootCert: caFile, SSLCert: certFile, SSLKey: keyFile}, false, true, 1, false},
+		{"cert without key", PostgresConfig{SSLCert: certFile}, false, false, 0, true},
+		{"key without cert", PostgresConfig{SSLKey: keyFile}, false, false, 0, true},
+		{"missing root", PostgresConfig{SSLRootCert: missing}, false, false, 0, true},
+		{"root not PEM", PostgresConfig{SSLRootCert: notPEM}, false, false, 0, true},
+		{"mismatched key", PostgresConfig{SSLCert: certFile, SSLKey: otherKey}, false, false, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tlsConfig, err := tt.config.TLSConfig()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TLSConfig error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if (tlsConfig == nil) != tt.wantNil {
+				t.Fatalf("TLSConfig = %v, want nil %v", tlsConfig, tt.wantNil)
+			}
+			if tt.wantNil {
+				return
+			}
+			if (tlsConfig.RootCAs != nil) != tt.wantRoots || len(tlsConfig.Certificates) != tt.wantCerts {
+				t.Fatalf("RootCAs set = %v, %d certificates, want %v, %d",
+					tlsConfig.RootCAs != nil, len(tlsConfig.Certificates), tt.wantRoots, tt.wantCerts)
+			}
+		})
+	}
+}
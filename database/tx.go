@@ -0,0 +1,125 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Tx wraps a Postgres transaction with the same helpers as PostgresDB
+type Tx struct {
+	tx   pgx.Tx
+	conn *pgxpool.Conn // returned to the pool by Commit or Rollback
+}
+
+// Begin starts a transaction on the primary
+func (p *PostgresDB) Begin() (*Tx, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := p.acquire(ctx, p.pool)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		conn.Release()
+		return nil, err
+	}
+
+	return &Tx{tx: tx, conn: conn}, nil
+}
+
+// WithTx runs fn in a transaction, committing if it returns nil and rolling back otherwise
+func (p *PostgresDB) WithTx(fn func(tx *Tx) error) error {
+	tx, err := p.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Query executes SQL inside the transaction and returns results as []map[string]any
+func (t *Tx) Query(sql string, args ...any) ([]map[string]any, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := t.tx.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	results, err := rowsToMaps(rows)
+	return results, translateError(err)
+}
+
+// Exec executes SQL inside the transaction and returns the number of affected rows
+func (t *Tx) Exec(sql string, args ...any) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := t.tx.Exec(ctx, sql, args...)
+	if err != nil {
+		return 0, translateError(err)
+	}
+
+	return result.RowsAffected(), nil
+}
+
+// Commit commits the transaction
+func (t *Tx) Commit() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	defer t.conn.Release()
+	return t.tx.Commit(ctx)
+}
+
+// Rollback aborts the transaction. Calling it after Commit is a no-op.
+func (t *Tx) Rollback() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	defer t.conn.Release()
+	err := t.tx.Rollback(ctx)
+	if errors.Is(err, pgx.ErrTxClosed) {
+		return nil
+	}
+	return err
+}
+
+// Savepoint marks a point the transaction can later roll back to
+func (t *Tx) Savepoint(name string) error {
+	return t.savepointCommand("SAVEPOINT ", name)
+}
+
+// RollbackTo undoes everything after the savepoint, keeping earlier work
+func (t *Tx) RollbackTo(name string) error {
+	return t.savepointCommand("ROLLBACK TO SAVEPOINT ", name)
+}
+
+// ReleaseSavepoint forgets the savepoint, keeping its changes
+func (t *Tx) ReleaseSavepoint(name string) error {
+	return t.savepointCommand("RELEASE SAVEPOINT ", name)
+}
+
+// Helper method
+func (t *Tx) savepointCommand(command, name string) error {
+	if name == "" {
+		return errors.New("savepoint name is required")
+	}
+
+	_, err := t.Exec(command + pgx.Identifier{name}.Sanitize())
+	return err
+}
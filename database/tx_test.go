@@ -0,0 +1,100 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestWithTx(t *testing.T) {
+	db := newTestPostgres(t, nil)
+	table := fmt.Sprintf("corego_tx_test_%d", time.Now().UnixNano())
+	if _, err := db.Exec("CREATE TABLE " + table + " (n int)"); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	t.Cleanup(func() { db.Exec("DROP TABLE " + table) })
+
+	insert := "INSERT INTO " + table + " (n) VALUES ($1)"
+	failed := errors.New("failed")
+
+	tests := []struct {
+		name    string
+		fn      func(tx *Tx) error
+		want    []int32
+		wantErr error
+	}{
+		{"commit", func(tx *Tx) error {
+			_, err := tx.Exec(insert, 1)
+			return err
+		}, []int32{1}, nil},
+		{"rollback on error", func(tx *Tx) error {
+			if _, err := tx.Exec(insert, 2); err != nil {
+				return err
+			}
+			return failed
+		}, nil, failed},
+		{"rollback to savepoint", func(tx *Tx) error {
+			if _, err := tx.Exec(insert, 3); err != nil {
+				return err
+			}
+			if err := tx.Savepoint("before_four"); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(insert, 4); err != nil {
+				return err
+			}
+			return tx.RollbackTo("before_four")
+		}, []int32{3}, nil},
+		{"released savepoint keeps changes", func(tx *Tx) error {
+			if err := tx.Savepoint("sp"); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(insert, 5); err != nil {
+				return err
+			}
+			return tx.ReleaseSavepoint("sp")
+		}, []int32{5}, nil},
+		{"quoted savepoint name", func(tx *Tx) error {
+			return tx.Savepoint(`sp"; DROP TABLE ` + table + `; --`)
+		}, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := db.Exec("DELETE FROM " + table); err != nil {
+				t.Fatalf("clear: %v", err)
+			}
+
+			if err := db.WithTx(tt.fn); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("WithTx error = %v, want %v", err, tt.wantErr)
+			}
+
+			rows, err := db.QueryPrimary("SELECT n FROM " + table + " ORDER BY n")
+			if err != nil {
+				t.Fatalf("select: %v", err)
+			}
+			var got []int32
+			for _, row := range rows {
+				got = append(got, row["n"].(int32))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("rows = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	if err := tx.Savepoint(""); err == nil {
+		t.Error("Savepoint with an empty name succeeded")
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback after Commit = %v, want nil", err)
+	}
+}
@@ -1,47 +1,171 @@
 package env
 
 import (
+	"errors"
+	"io/fs"
 	"log"
 	"os"
+	"maps"
+	"strings"
+	"sync"
+	"sync/atomic"
+
 	"github.com/joho/godotenv"
 )
 
 type Env struct {
+	// Typed fields and MANUAL hold the values read by LoadEnv. Reload doesn't
+	// change them, since other goroutines may be reading them; use the accessor
+	// methods for values that follow Reload.
 	URL							string `mapstructure:"URL"`
 	PORT						string `mapstructure:"PORT"`
 	MONGODB_CONNECTION_URL		*string `mapstructure:"MONGODB_CONNECTION_URL"`
 	POSTGRES_CONNECTION_URL		*string `mapstructure:"POSTGRES_CONNECTION_URL"`
 	MANUAL 						map[string]string
+
+	current		atomic.Pointer[values] // replaced as a whole by each load
+	mu			sync.Mutex // serializes loads
+	dotenvKeys	map[string]bool
+}
+
+// values is one immutable snapshot of the environment
+type values struct {
+	url			string
+	port		string
+	mongoURL	string
+	postgresURL	string
+	all			map[string]string
 }
 
 func LoadEnv() (*Env){
-	err := godotenv.Load(".env")
+	e := &Env{dotenvKeys: make(map[string]bool)}
 
-	if err != nil {
+	if err := e.load(); err != nil {
 		log.Printf("Warning: .env file not found, using defaults.")
 	}
 
-	url := os.Getenv("URL")
-    port := os.Getenv("PORT")
+	v := e.current.Load()
+	e.URL = v.url
+	e.PORT = v.port
+	if v.mongoURL != "" {
+		mongoURL := v.mongoURL
+		e.MONGODB_CONNECTION_URL = &mongoURL
+	}
+	if v.postgresURL != "" {
+		postgresURL := v.postgresURL
+		e.POSTGRES_CONNECTION_URL = &postgresURL
+	}
+	e.MANUAL = maps.Clone(v.all)
+
+	return e
+}
+
+// Reload re-reads .env and the process environment and atomically swaps in the
+// new values, which the accessor methods return from then on. Variables set by
+// the real environment keep precedence over .env, as in LoadEnv.
+func (e *Env) Reload() error {
+	err := e.load()
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Helper method
+func (e *Env) load() error {
+	file, err := godotenv.Read(".env")
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// Apply .env without overriding variables that came from the real environment
+	fromFile := make(map[string]bool)
+	for key, value := range file {
+		if _, set := os.LookupEnv(key); !set || e.dotenvKeys[key] {
+			os.Setenv(key, value)
+			fromFile[key] = true
+		}
+	}
+	// Keys that were removed from .env since the last load
+	for key := range e.dotenvKeys {
+		if !fromFile[key] {
+			os.Unsetenv(key)
+		}
+	}
+	e.dotenvKeys = fromFile
+
+	v := &values{
+		url:         os.Getenv("URL"),
+		port:        os.Getenv("PORT"),
+		mongoURL:    os.Getenv("MONGODB_CONNECTION_URL"),
+		postgresURL: os.Getenv("POSTGRES_CONNECTION_URL"),
+		all:         make(map[string]string),
+	}
 
 	//Default Values
-	if url == "" {
-        url = "http://localhost"
-    }
-    if port == "" {
-        port = "8080"
-    }
-
-	//Optional Values
-	var mongoURL *string
-	if mongoEnv := os.Getenv("MONGODB_CONNECTION_URL"); mongoEnv != "" {
-		mongoURL = &mongoEnv
-	}
-
-	return &Env{
-		URL: url,
-		PORT: port,
-		MONGODB_CONNECTION_URL: mongoURL,
-		MANUAL: make(map[string]string),
-	}
-}
\ No newline at end of file
+	if v.url == "" {
+		v.url = "http://localhost"
+	}
+	if v.port == "" {
+		v.port = "8080"
+	}
+
+	//All Values, including the ones loaded from .env
+	for _, kv := range os.Environ() {
+		if key, value, ok := strings.Cut(kv, "="); ok {
+			v.all[key] = value
+		}
+	}
+
+	e.current.Store(v)
+	return err
+}
+
+// snapshot returns the latest values; a zero Env reads as empty
+func (e *Env) snapshot() *values {
+	if v := e.current.Load(); v != nil {
+		return v
+	}
+	return &values{}
+}
+
+// BaseURL returns URL as of the last load (default: "http://localhost")
+func (e *Env) BaseURL() string {
+	return e.snapshot().url
+}
+
+// Port returns PORT as of the last load (default: "8080")
+func (e *Env) Port() string {
+	return e.snapshot().port
+}
+
+// MongoConnectionURL returns MONGODB_CONNECTION_URL as of the last load and whether it is set
+func (e *Env) MongoConnectionURL() (string, bool) {
+	url := e.snapshot().mongoURL
+	return url, url != ""
+}
+
+// PostgresConnectionURL returns POSTGRES_CONNECTION_URL as of the last load and whether it is set
+func (e *Env) PostgresConnectionURL() (string, bool) {
+	url := e.snapshot().postgresURL
+	return url, url != ""
+}
+
+// Get returns the value of any environment variable, or "" if unset
+func (e *Env) Get(key string) string {
+	return e.snapshot().all[key]
+}
+
+// Lookup returns the value of any environment variable and whether it is set
+func (e *Env) Lookup(key string) (string, bool) {
+	value, ok := e.snapshot().all[key]
+	return value, ok
+}
+
+// GetOrDefault returns the value of any environment variable, or fallback if unset or empty
+func (e *Env) GetOrDefault(key, fallback string) string {
+	if value := e.Get(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,139 @@
+package env
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func writeDotenv(t *testing.T, dir, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte(content), 0o600); err != nil {
+		t.Fatalf("write .env: %v", err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	tests := []struct {
+		name    string
+		before  string
+		after   string
+		process map[string]string // set in the real environment
+		key     string
+		want    string
+		wantSet bool
+	}{
+		{"changed value", "COREGO_TEST_A=1\n", "COREGO_TEST_A=2\n", nil, "COREGO_TEST_A", "2", true},
+		{"added value", "", "COREGO_TEST_B=new\n", nil, "COREGO_TEST_B", "new", true},
+		{"removed value", "COREGO_TEST_C=old\n", "", nil, "COREGO_TEST_C", "", false},
+		{"real environment wins", "", "COREGO_TEST_D=file\n", map[string]string{"COREGO_TEST_D": "process"}, "COREGO_TEST_D", "process", true},
+		{"typed accessor", "PORT=3000\n", "PORT=4000\n", nil, "PORT", "4000", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Chdir(dir)
+			// Registers cleanup for variables the loads set through .env
+			t.Setenv(tt.key, "")
+			os.Unsetenv(tt.key)
+			for key, value := range tt.process {
+				t.Setenv(key, value)
+			}
+
+			writeDotenv(t, dir, tt.before)
+			e := LoadEnv()
+			writeDotenv(t, dir, tt.after)
+			if err := e.Reload(); err != nil {
+				t.Fatalf("Reload: %v", err)
+			}
+
+			got, ok := e.Lookup(tt.key)
+			if got != tt.want || ok != tt.wantSet {
+				t.Fatalf("Lookup(%s) = %q, %v, want %q, %v", tt.key, got, ok, tt.want, tt.wantSet)
+			}
+			if tt.key == "PORT" && e.Port() != tt.want {
+				t.Fatalf("Port() = %q, want %q", e.Port(), tt.want)
+			}
+		})
+	}
+}
+
+// Run with -race: readers must not race with Reload
+func TestReloadConcurrentReaders(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	t.Setenv("COREGO_TEST_FLAG", "")
+	os.Unsetenv("COREGO_TEST_FLAG")
+
+	writeDotenv(t, dir, "COREGO_TEST_FLAG=on\n")
+	e := LoadEnv()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				e.Get("COREGO_TEST_FLAG")
+				e.Port()
+				e.MongoConnectionURL()
+			}
+		}()
+	}
+	for j := 0; j < 50; j++ {
+		if err := e.Reload(); err != nil {
+			t.Errorf("Reload: %v", err)
+		}
+	}
+	wg.Wait()
+}
+
+func TestAccessors(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	for _, key := range []string{"URL", "PORT", "MONGODB_CONNECTION_URL", "POSTGRES_CONNECTION_URL", "COREGO_TEST_FILE", "COREGO_TEST_EMPTY"} {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+	t.Setenv("COREGO_TEST_EMPTY", "")
+	t.Setenv("COREGO_TEST_PROCESS", "process")
+	writeDotenv(t, dir, "COREGO_TEST_FILE=file\nMONGODB_CONNECTION_URL=mongodb://localhost\n")
+
+	e := LoadEnv()
+
+	tests := []struct {
+		name   string
+		get    func() (string, bool)
+		want   string
+		wantOK bool
+	}{
+		{"from .env", func() (string, bool) { return e.Lookup("COREGO_TEST_FILE") }, "file", true},
+		{"from the process", func() (string, bool) { return e.Lookup("COREGO_TEST_PROCESS") }, "process", true},
+		{"set but empty", func() (string, bool) { return e.Lookup("COREGO_TEST_EMPTY") }, "", true},
+		{"unset", func() (string, bool) { return e.Lookup("COREGO_TEST_MISSING") }, "", false},
+		{"Get unset", func() (string, bool) { return e.Get("COREGO_TEST_MISSING"), true }, "", true},
+		{"GetOrDefault set", func() (string, bool) { return e.GetOrDefault("COREGO_TEST_FILE", "x"), true }, "file", true},
+		{"GetOrDefault empty", func() (string, bool) { return e.GetOrDefault("COREGO_TEST_EMPTY", "x"), true }, "x", true},
+		{"BaseURL default", func() (string, bool) { return e.BaseURL(), true }, "http://localhost", true},
+		{"Port default", func() (string, bool) { return e.Port(), true }, "8080", true},
+		{"Mongo URL set", e.MongoConnectionURL, "mongodb://localhost", true},
+		{"Postgres URL unset", e.PostgresConnectionURL, "", false},
+		{"MANUAL includes .env", func() (string, bool) { v, ok := e.MANUAL["COREGO_TEST_FILE"]; return v, ok }, "file", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.get()
+			if got != tt.want || ok != tt.wantOK {
+				t.Fatalf("got %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+
+	var zero Env
+	if zero.Get("PATH") != "" || zero.Port() != "" {
+		t.Fatal("a zero Env should read as empty")
+	}
+}
@@ -0,0 +1,70 @@
+package corego
+
+import (
+	"time"
+
+	"github.com/berkkaradalan/CoreGo/database"
+	"github.com/gin-gonic/gin"
+)
+
+// DependencyHealth is the result of pinging a single dependency
+type DependencyHealth struct {
+	Healthy   bool    `json:"healthy"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+
+	Pool *database.PoolStats `json:"pool,omitempty"`
+}
+
+// HealthStatus reports the overall status plus per-dependency details
+type HealthStatus struct {
+	Healthy      bool                        `json:"healthy"`
+	Dependencies map[string]DependencyHealth `json:"dependencies"`
+}
+
+// Health pings every configured database and reports latency and errors
+func (c *Core) Health() HealthStatus {
+	status := HealthStatus{
+		Healthy:      true,
+		Dependencies: make(map[string]DependencyHealth),
+	}
+
+	if c.Mongo != nil {
+		status.add("mongodb", c.Mongo.Ping, c.Mongo.Stat())
+	}
+	if c.Postgres != nil {
+		status.add("postgres", c.Postgres.Ping, c.Postgres.Stat())
+	}
+
+	return status
+}
+
+// HealthHandler returns Gin handler responding 200 when healthy and 503 otherwise
+func (c *Core) HealthHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		status := c.Health()
+		if !status.Healthy {
+			ctx.JSON(503, status)
+			return
+		}
+		ctx.JSON(200, status)
+	}
+}
+
+// Helper method
+func (s *HealthStatus) add(name string, ping func() error, pool database.PoolStats) {
+	start := time.Now()
+	err := ping()
+
+	dep := DependencyHealth{
+		Healthy:   err == nil,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		Pool:      &pool,
+	}
+	if err != nil {
+		dep.Error = err.Error()
+		s.Healthy = false
+	}
+
+	s.Dependencies[name] = dep
+}
@@ -0,0 +1,61 @@
+package corego
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/berkkaradalan/CoreGo/database"
+	"github.com/gin-gonic/gin"
+)
+
+func TestHealthStatusAdd(t *testing.T) {
+	ok := func() error { return nil }
+	down := func() error { return errors.New("connection refused") }
+
+	tests := []struct {
+		name        string
+		pings       map[string]func() error
+		wantHealthy bool
+	}{
+		{"no dependencies", nil, true},
+		{"all up", map[string]func() error{"mongodb": ok, "postgres": ok}, true},
+		{"one down", map[string]func() error{"mongodb": ok, "postgres": down}, false},
+		{"all down", map[string]func() error{"mongodb": down, "postgres": down}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := HealthStatus{Healthy: true, Dependencies: make(map[string]DependencyHealth)}
+			for name, ping := range tt.pings {
+				status.add(name, ping, database.PoolStats{})
+			}
+
+			if status.Healthy != tt.wantHealthy {
+				t.Fatalf("Healthy = %v, want %v", status.Healthy, tt.wantHealthy)
+			}
+			for name, dep := range status.Dependencies {
+				failed := tt.pings[name]() != nil
+				if dep.Healthy == failed || (dep.Error != "") != failed {
+					t.Errorf("%s = %+v, want healthy %v", name, dep, !failed)
+				}
+				if dep.LatencyMs < 0 || dep.Pool == nil {
+					t.Errorf("%s = %+v, want latency and pool stats", name, dep)
+				}
+			}
+		})
+	}
+}
+
+func TestHealthHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/health", (&Core{}).HealthHandler())
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+	if w.Code != 200 {
+		t.Fatalf("status = %d, want 200 without dependencies", w.Code)
+	}
+}
@@ -0,0 +1,20 @@
+package corego
+
+import (
+	"github.com/berkkaradalan/CoreGo/auth"
+	"github.com/gin-gonic/gin"
+)
+
+// UseJSONErrors makes unmatched routes respond with the standard JSON error
+// envelope instead of Gin's plain text: 404 for unknown paths and 405 for
+// known paths requested with an unsupported method.
+func UseJSONErrors(r *gin.Engine) {
+	r.HandleMethodNotAllowed = true
+
+	r.NoRoute(func(c *gin.Context) {
+		auth.RespondError(c, 404, auth.CodeNotFound, "route not found")
+	})
+	r.NoMethod(func(c *gin.Context) {
+		auth.RespondError(c, 405, auth.CodeMethodNotAllowed, "method not allowed")
+	})
+}
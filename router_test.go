@@ -0,0 +1,37 @@
+package corego
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestUseJSONErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	UseJSONErrors(router)
+	router.GET("/items", func(c *gin.Context) { c.String(200, "items") })
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"known route", "GET", "/items", 200, "items"},
+		{"unknown path", "GET", "/missing", 404, `{"error":{"code":"not_found","message":"route not found"}}`},
+		{"unsupported method", "DELETE", "/items", 405, `{"error":{"code":"method_not_allowed","message":"method not allowed"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != tt.wantStatus || w.Body.String() != tt.wantBody {
+				t.Fatalf("response = %d %s, want %d %s", w.Code, w.Body.String(), tt.wantStatus, tt.wantBody)
+			}
+		})
+	}
+}